@@ -0,0 +1,94 @@
+// Package sobres es el formato de los sobres y sus Ack, lo único que se
+// dicen por el servidor la mutua, los talleres y el propio servidor además
+// de los códigos sueltos. Cada mensaje es una línea JSON.
+package sobres
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sobre es un código que la mutua quiere ver confirmado. El servidor lo
+// reparte tal cual y, cuando ya ha llegado a todos los conectados, reparte
+// OK(Emisor, ID). Emisor es la mutua que lo envía; ID, su número de
+// secuencia en su Sesion (la hora a la que arrancó): los talleres descartan
+// los duplicados y los atrasados. Tema separa los estados que pide un mismo
+// emisor por cuenta de varios sistemas (cada tema es una fuente en los
+// talleres); Motivo es solo para el registro.
+type Sobre struct {
+	ID     uint64 `json:"id"`
+	Codigo int    `json:"codigo"`
+	Tema   string `json:"tema,omitempty"`
+	Motivo string `json:"motivo,omitempty"`
+	Emisor string `json:"emisor,omitempty"`
+	Sesion int64  `json:"sesion,omitempty"`
+
+	// Lease: el estado pedido vale este tiempo (en JSON, nanosegundos) si
+	// no llega otro sobre del emisor y tema que lo renueve; 0 = para siempre.
+	Lease time.Duration `json:"lease,omitempty"`
+}
+
+// Qué ha hecho un taller con un sobre (Ack.Resultado).
+const (
+	ResultadoAplicado  = "aplicado"
+	ResultadoDuplicado = "duplicado" // ya se había aplicado: no se repite
+	ResultadoObsoleto  = "obsoleto"  // llegó tarde, con otro posterior ya aplicado
+)
+
+// Ack es la respuesta de un taller a un Sobre. El servidor la reparte a
+// todos; es para la mutua de Origen (el emisor del sobre) y los demás,
+// talleres incluidos, la ignoran.
+type Ack struct {
+	Ack       uint64    `json:"ack"` // ID del sobre
+	Codigo    int       `json:"codigo"`
+	Origen    string    `json:"origen"`
+	Taller    string    `json:"taller"` // quién lo aplicó
+	Aplicado  time.Time `json:"aplicado"`
+	Estado    string    `json:"estado"` // resumen del estado tras aplicarlo
+	Resultado string    `json:"resultado"`
+}
+
+// Abrir interpreta una línea como Sobre (JSON que no es un Ack).
+func Abrir(linea string) (Sobre, bool) {
+	var s struct {
+		Sobre
+		Ack *uint64 `json:"ack"`
+	}
+	if !strings.HasPrefix(linea, "{") || json.Unmarshal([]byte(linea), &s) != nil || s.Ack != nil {
+		return Sobre{}, false
+	}
+	return s.Sobre, true
+}
+
+// AbrirAck interpreta una línea como Ack.
+func AbrirAck(linea string) (Ack, bool) {
+	var a struct {
+		Ack
+		ID *uint64 `json:"ack"`
+	}
+	if !strings.HasPrefix(linea, "{") || json.Unmarshal([]byte(linea), &a) != nil || a.ID == nil {
+		return Ack{}, false
+	}
+	a.Ack.Ack = *a.ID
+	return a.Ack, true
+}
+
+// OK es la línea con la que el servidor confirma el sobre id de emisor.
+func OK(emisor string, id uint64) string {
+	return "ok " + emisor + " " + strconv.FormatUint(id, 10)
+}
+
+// AbrirOK interpreta una línea como la confirmación de un sobre.
+func AbrirOK(linea string) (emisor string, id uint64, ok bool) {
+	campos := strings.Fields(linea)
+	if len(campos) != 3 || campos[0] != "ok" {
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(campos[2], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return campos[1], id, true
+}
@@ -0,0 +1,54 @@
+package sobres
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Lo que escribe la mutua es lo que leen el servidor y los talleres, lease
+// incluido (en nanosegundos).
+func TestAbrir_IdaYVuelta(t *testing.T) {
+	s := Sobre{ID: 3, Codigo: 4, Tema: "turnos", Emisor: "mutua-norte", Sesion: 9, Lease: 2 * time.Second}
+	data, _ := json.Marshal(s)
+	if got, ok := Abrir(string(data)); !ok || got != s {
+		t.Fatalf("%s: %+v %v", data, got, ok)
+	}
+	if got, ok := Abrir(`{"id":1,"codigo":2,"emisor":"norte","lease":1000000000}`); !ok || got.Lease != time.Second {
+		t.Fatalf("lease: %+v %v", got, ok)
+	}
+}
+
+// Los Ack también llegan por el broadcast: no son sobres, ni los sobres
+// son Ack. Un código suelto no es ninguna de las dos cosas.
+func TestAbrir_NoEsAck(t *testing.T) {
+	const ack = `{"ack":3,"codigo":4,"origen":"mutua-norte","taller":"127.0.0.1:5000","resultado":"aplicado"}`
+	if s, ok := Abrir(ack); ok {
+		t.Fatalf("un Ack se ha leído como sobre: %+v", s)
+	}
+	if a, ok := AbrirAck(ack); !ok || a.Ack != 3 || a.Origen != "mutua-norte" || a.Resultado != ResultadoAplicado {
+		t.Fatalf("ack: %+v %v", a, ok)
+	}
+	if a, ok := AbrirAck(`{"id":3,"codigo":4,"emisor":"mutua-norte"}`); ok {
+		t.Fatalf("un sobre se ha leído como Ack: %+v", a)
+	}
+	for _, linea := range []string{"3", "ok mutua-norte 3"} {
+		if _, ok := Abrir(linea); ok {
+			t.Errorf("%q se ha leído como sobre", linea)
+		}
+		if _, ok := AbrirAck(linea); ok {
+			t.Errorf("%q se ha leído como Ack", linea)
+		}
+	}
+}
+
+func TestAbrirOK(t *testing.T) {
+	if emisor, id, ok := AbrirOK(OK("mutua-norte", 7)); !ok || emisor != "mutua-norte" || id != 7 {
+		t.Fatalf("%q %d %v", emisor, id, ok)
+	}
+	for _, linea := range []string{"3", "ok", "ok mutua-norte", "ok mutua-norte siete", `{"id":7}`} {
+		if _, _, ok := AbrirOK(linea); ok {
+			t.Errorf("%q se ha leído como ok", linea)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var consolaOn = flag.Bool("consola", false, "consola interactiva: se escriben los códigos o sus nombres y se envían al momento")
+
+// nombresCodigo son los nombres que entiende la consola para cada código.
+var nombresCodigo = map[string]int{
+	"inactivo":        0,
+	"solo a":          1,
+	"solo b":          2,
+	"solo c":          3,
+	"prioridad a":     4,
+	"prioridad b":     5,
+	"prioridad c":     6,
+	"cerrar":          9,
+	"cerrado":         9,
+	"mecanico fuera":  10,
+	"mecanico vuelve": 11,
+}
+
+// nombreCodigo es el nombre con el que se muestra un código.
+func nombreCodigo(code int) string {
+	switch code {
+	case 0:
+		return "INACTIVO"
+	case 1, 2, 3:
+		return "SOLO " + string(rune('A'+code-1))
+	case 4, 5, 6:
+		return "PRIORIDAD " + string(rune('A'+code-4))
+	case 9:
+		return "CERRADO"
+	case 10:
+		return "MECÁNICO FUERA"
+	case 11:
+		return "MECÁNICO VUELVE"
+	}
+	return "sin definir"
+}
+
+// sinTildes quita las tildes, para aceptar los nombres tal como se
+// muestran ("MECÁNICO FUERA") o sin ellas.
+var sinTildes = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u")
+
+// interpretar convierte lo escrito (un número 0..11 o un nombre, sin
+// importar mayúsculas, tildes ni espacios de más) en un código.
+func interpretar(s string) (int, error) {
+	s = sinTildes.Replace(strings.ToLower(strings.Join(strings.Fields(s), " ")))
+	if code, err := strconv.Atoi(s); err == nil {
+		if code < 0 || code > 11 {
+			return 0, fmt.Errorf("código fuera de rango: %d (0..11)", code)
+		}
+		return code, nil
+	}
+	if code, ok := nombresCodigo[s]; ok {
+		return code, nil
+	}
+	return 0, fmt.Errorf("no se entiende %q; escribe 'ayuda'", s)
+}
+
+const ayudaConsola = `Órdenes:
+  0..11, inactivo, solo a|b|c, prioridad a|b|c, cerrar, mecánico fuera, mecánico vuelve
+  historial     lista lo enviado
+  !!            repite el último
+  !n            repite el n del historial
+  estado        muestra la línea de estado
+  salir`
+
+// ejecutarConsola lee órdenes de in y envía por dst los códigos que
+// resulten. Lo que manda el servidor se descarta; solo sirve para saber si
+// la conexión sigue viva.
+func ejecutarConsola(dst net.Conn, in io.Reader, out io.Writer) {
+	// Con enlace, él es quien lee la conexión.
+	var caida <-chan struct{}
+	if enlace != nil {
+		caida = enlace.Caida()
+	} else {
+		c := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, dst)
+			close(c)
+		}()
+		caida = c
+	}
+
+	lineas := make(chan string)
+	go func() {
+		input := bufio.NewScanner(in)
+		for input.Scan() {
+			lineas <- input.Text()
+		}
+		close(lineas)
+	}()
+
+	var historial []int
+	conectado := true
+	estado := func() {
+		ultimo := "ninguno"
+		if len(historial) > 0 {
+			code := historial[len(historial)-1]
+			ultimo = fmt.Sprintf("%d (%s)", code, nombreCodigo(code))
+		}
+		conexion := "conectado a " + dst.RemoteAddr().String()
+		if !conectado {
+			conexion = "DESCONECTADO"
+		}
+		fmt.Fprintf(out, "[último: %s | %s | enviados: %d]\n", ultimo, conexion, len(historial))
+	}
+	enviar := func(code int) {
+		if !conectado {
+			fmt.Fprintln(out, "no se envía: la conexión con el servidor se ha cerrado")
+			return
+		}
+		enviarCodigo(dst, code)
+		historial = append(historial, code)
+		estado()
+	}
+
+	fmt.Fprintln(out, ayudaConsola)
+	estado()
+	for {
+		fmt.Fprint(out, "mutua> ")
+		var linea string
+		var ok bool
+		select {
+		case linea, ok = <-lineas:
+			if !ok {
+				return
+			}
+		case <-caida:
+			caida = nil
+			conectado = false
+			fmt.Fprintln(out, "\nel servidor ha cerrado la conexión")
+			estado()
+			continue
+		}
+
+		orden := strings.TrimSpace(linea)
+		switch {
+		case orden == "":
+		case orden == "salir":
+			return
+		case orden == "ayuda":
+			fmt.Fprintln(out, ayudaConsola)
+		case orden == "estado":
+			estado()
+		case orden == "historial":
+			for i, code := range historial {
+				fmt.Fprintf(out, "%3d  %2d %s\n", i+1, code, nombreCodigo(code))
+			}
+		case orden == "!!":
+			if len(historial) == 0 {
+				fmt.Fprintln(out, "no se ha enviado nada")
+				continue
+			}
+			enviar(historial[len(historial)-1])
+		case strings.HasPrefix(orden, "!"):
+			n, err := strconv.Atoi(orden[1:])
+			if err != nil || n < 1 || n > len(historial) {
+				fmt.Fprintf(out, "no hay %q en el historial\n", orden)
+				continue
+			}
+			enviar(historial[n-1])
+		default:
+			code, err := interpretar(orden)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			enviar(code)
+		}
+	}
+}
+
+// consola es ejecutarConsola con la entrada y salida estándar.
+func consola(dst net.Conn) {
+	ejecutarConsola(dst, os.Stdin, os.Stdout)
+}
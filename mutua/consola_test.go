@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Cada código se entiende por su número y por el nombre con el que lo
+// muestra la consola (con tildes o sin ellas, en mayúsculas o no).
+func TestInterpretar(t *testing.T) {
+	for code := 0; code <= 11; code++ {
+		if code == 7 || code == 8 {
+			continue
+		}
+		for _, s := range []string{fmt.Sprint(code), nombreCodigo(code), strings.ToLower(nombreCodigo(code))} {
+			if got, err := interpretar(s); err != nil || got != code {
+				t.Errorf("%q: %d %v, quería %d", s, got, err, code)
+			}
+		}
+	}
+	for s, want := range map[string]int{
+		"  Mecanico   FUERA ": 10,
+		"mecánico vuelve":     11,
+		"cerrar":              9,
+		" 7 ":                 7,
+	} {
+		if got, err := interpretar(s); err != nil || got != want {
+			t.Errorf("%q: %d %v, quería %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"12", "-1", "solo d", "abrir", ""} {
+		if _, err := interpretar(s); err == nil {
+			t.Errorf("%q: sin error", s)
+		}
+	}
+}
+
+// salida es un io.Writer que se puede leer mientras la consola escribe.
+type salida struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *salida) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *salida) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+// consolaDePrueba conecta ejecutarConsola a un servidor de mentira y
+// devuelve lo que le llega a este, línea a línea.
+func consolaDePrueba(t *testing.T, in io.Reader, out io.Writer) (servidor net.Conn, recibidos <-chan string, fin <-chan struct{}) {
+	t.Helper()
+	mutua, srv := net.Pipe()
+	lineas := make(chan string, 100)
+	go func() {
+		input := bufio.NewScanner(srv)
+		for input.Scan() {
+			lineas <- input.Text()
+		}
+		close(lineas)
+	}()
+	hecho := make(chan struct{})
+	go func() {
+		ejecutarConsola(mutua, in, out)
+		mutua.Close()
+		close(hecho)
+	}()
+	return srv, lineas, hecho
+}
+
+// Las órdenes de la consola: códigos y nombres, repetir, historial y los
+// errores, que no envían nada.
+func TestEjecutarConsola(t *testing.T) {
+	in := strings.NewReader("solo b\n!!\nMECÁNICO FUERA\n!1\n99\nabrir\n!7\nhistorial\nestado\nsalir\n3\n")
+	out := &salida{}
+	_, recibidos, fin := consolaDePrueba(t, in, out)
+
+	var enviados []string
+	for l := range recibidos {
+		enviados = append(enviados, l)
+	}
+	<-fin
+	if want := []string{"2", "2", "10", "2"}; fmt.Sprint(enviados) != fmt.Sprint(want) {
+		t.Fatalf("enviados %v, quería %v (tras salir no se envía nada)", enviados, want)
+	}
+	for _, want := range []string{
+		"código fuera de rango: 99",
+		`no se entiende "abrir"`,
+		`no hay "!7" en el historial`,
+		"  3  10 MECÁNICO FUERA",
+		"[último: 2 (SOLO B) | conectado a pipe | enviados: 4]",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("falta %q en la salida:\n%s", want, out)
+		}
+	}
+}
+
+// Si el servidor cierra la conexión, la consola lo dice y deja de enviar.
+func TestEjecutarConsola_Desconectado(t *testing.T) {
+	in, escribir := io.Pipe()
+	out := &salida{}
+	srv, _, fin := consolaDePrueba(t, in, out)
+
+	srv.Close()
+	for limite := time.Now().Add(5 * time.Second); !strings.Contains(out.String(), "DESCONECTADO"); {
+		if time.Now().After(limite) {
+			t.Fatalf("no avisa de la desconexión:\n%s", out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fmt.Fprintln(escribir, "solo a")
+	escribir.Close()
+	<-fin
+	if !strings.Contains(out.String(), "no se envía: la conexión con el servidor se ha cerrado") {
+		t.Fatalf("envía sin conexión:\n%s", out)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+var httpAddr = flag.String("http", "", "modo demonio: dirección de la API HTTP (p.ej. localhost:8090) que recibe los cambios de estado")
+
+type estadoReq struct {
+	Codigo *int   `json:"codigo"`
+	Tema   string `json:"tema"`
+	Motivo string `json:"motivo"`
+}
+
+type estadoResp struct {
+	sobres.Sobre
+	Nombre     string `json:"nombre"`
+	Confirmado bool   `json:"confirmado"`
+	Error      string `json:"error,omitempty"`
+}
+
+// servirHTTP es el modo demonio. Bloquea atendiendo la API de rutasDaemon.
+func servirHTTP(addr string, e *Enlace) {
+	fmt.Println("Mutua en modo demonio, API en " + addr)
+	log.Fatal(http.ListenAndServe(addr, rutasDaemon(e)))
+}
+
+// rutasDaemon es la API del modo demonio:
+//
+//	POST /estado  {"codigo": 0..11, "tema": "...", "motivo": "..."}
+//	              200 cuando el servidor confirma la entrega, 504 si no
+//	              confirma a tiempo, 502 si no hay conexión con él
+//
+// El tema distingue en los talleres los estados que pide cada sistema a
+// través de esta mutua (ver Codigo.fuente en el taller); el motivo viaja
+// con el sobre para quien lo registre.
+func rutasDaemon(e *Enlace) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /estado", func(w http.ResponseWriter, r *http.Request) {
+		var req estadoReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			responderJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if req.Codigo == nil || *req.Codigo < 0 || *req.Codigo > 11 {
+			responderJSON(w, http.StatusBadRequest, map[string]string{"error": "codigo debe estar entre 0 y 11"})
+			return
+		}
+
+		c := e.Enviar(sobres.Sobre{Codigo: *req.Codigo, Tema: req.Tema, Motivo: req.Motivo})
+		resp := estadoResp{Sobre: c.Sobre, Nombre: nombreCodigo(c.Sobre.Codigo), Confirmado: c.Err == nil}
+		switch {
+		case c.Err == nil:
+			responderJSON(w, http.StatusOK, resp)
+		case errors.Is(c.Err, errSinConfirmar):
+			resp.Error = c.Err.Error()
+			responderJSON(w, http.StatusGatewayTimeout, resp)
+		default:
+			resp.Error = c.Err.Error()
+			responderJSON(w, http.StatusBadGateway, resp)
+		}
+	})
+	return mux
+}
+
+func responderJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
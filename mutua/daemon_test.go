@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+// servidorFalso hace de servidor para un enlace: lee los enviados y, según
+// responde, contesta "ok <emisor> <id>" o se calla. Devuelve los sobres
+// leídos y la conexión del lado del servidor (para cerrarla).
+func servidorFalso(t *testing.T, responde bool) (*Enlace, <-chan sobres.Sobre, net.Conn) {
+	t.Helper()
+	mutua, srv := net.Pipe()
+	t.Cleanup(func() { srv.Close() })
+	enviados := make(chan sobres.Sobre, 10)
+	go func() {
+		input := bufio.NewScanner(srv)
+		for input.Scan() {
+			s, ok := sobres.Abrir(input.Text())
+			if !ok {
+				continue
+			}
+			enviados <- s
+			if responde {
+				go srv.Write([]byte(sobres.OK(s.Emisor, s.ID) + "\n"))
+			}
+		}
+	}()
+	return nuevoEnlace(mutua, "mutua-prueba", 200*time.Millisecond, 0, 0, 0), enviados, srv
+}
+
+func postEstado(t *testing.T, h http.Handler, cuerpo string) (int, estadoResp) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/estado", strings.NewReader(cuerpo)))
+	var resp estadoResp
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+// 200 solo cuando el servidor confirma; el sobre lleva el tema y el motivo.
+func TestDaemon_Confirmado(t *testing.T) {
+	e, enviados, _ := servidorFalso(t, true)
+	code, resp := postEstado(t, rutasDaemon(e), `{"codigo": 3, "tema": "turnos", "motivo": "falta personal"}`)
+	if code != http.StatusOK || !resp.Confirmado || resp.Nombre != nombreCodigo(3) {
+		t.Fatalf("respuesta %d %+v", code, resp)
+	}
+	s := <-enviados
+	if s.Codigo != 3 || s.Tema != "turnos" || s.Motivo != "falta personal" || s.Emisor != "mutua-prueba" || s.ID != resp.ID {
+		t.Fatalf("sobre enviado %+v, respuesta %+v", s, resp)
+	}
+}
+
+// 504 si el servidor no confirma dentro del timeout.
+func TestDaemon_SinConfirmar(t *testing.T) {
+	e, _, _ := servidorFalso(t, false)
+	code, resp := postEstado(t, rutasDaemon(e), `{"codigo": 0}`)
+	if code != http.StatusGatewayTimeout || resp.Confirmado || resp.Error != errSinConfirmar.Error() {
+		t.Fatalf("respuesta %d %+v", code, resp)
+	}
+}
+
+// 502 si la conexión con el servidor se ha cerrado.
+func TestDaemon_Desconectado(t *testing.T) {
+	e, _, srv := servidorFalso(t, true)
+	srv.Close()
+	<-e.Caida()
+	code, resp := postEstado(t, rutasDaemon(e), `{"codigo": 9}`)
+	if code != http.StatusBadGateway || resp.Confirmado || resp.Error != errDesconectado.Error() {
+		t.Fatalf("respuesta %d %+v", code, resp)
+	}
+}
+
+// Las peticiones mal formadas no llegan al servidor.
+func TestDaemon_PeticionMala(t *testing.T) {
+	e, enviados, _ := servidorFalso(t, true)
+	for _, cuerpo := range []string{`{"codigo": 12}`, `{"tema": "x"}`, `codigo=3`} {
+		if code, _ := postEstado(t, rutasDaemon(e), cuerpo); code != http.StatusBadRequest {
+			t.Errorf("%s: %d, quería 400", cuerpo, code)
+		}
+	}
+	select {
+	case s := <-enviados:
+		t.Fatalf("se ha enviado %+v", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+var (
+	confTimeout = flag.Duration("timeout", 5*time.Second, "tiempo máximo esperando a que el servidor confirme el reparto de un código (modo demonio)")
+	ackOn       = flag.Bool("ack", false, "envía los códigos en sobres y espera el Ack de algún taller; avisa de los que no lo reciben")
+	ackTimeout  = flag.Duration("ack-timeout", 5*time.Second, "tiempo máximo esperando el Ack de un taller")
+	reintentos  = flag.Int("reintentos", 0, "veces que se reenvía un código sin Ack antes de darlo por perdido")
+	lease       = flag.Duration("lease", 0, "los estados pedidos caducan en los talleres tras este tiempo; la mutua los renueva mientras sigue en marcha (0 = no caducan)")
+	emisor      = flag.String("emisor", "", "nombre de esta mutua en los sobres (para la secuencia de los talleres); vacío = mutua-<pid>")
+)
+
+// salidaEnlace es donde el enlace cuenta lo que envía y los Ack que recibe
+// (los tests la cambian).
+var salidaEnlace io.Writer = os.Stdout
+
+// recuerdoResueltos es cuánto se recuerda un sobre ya confirmado. Todos los
+// talleres responden a cada sobre: basta el primer Ack y los de los demás
+// llegan después, casi a la vez.
+const recuerdoResueltos = time.Minute
+
+var (
+	errSinConfirmar = errors.New("el servidor no ha confirmado a tiempo")
+	errDesconectado = errors.New("conexión con el servidor cerrada")
+)
+
+// enlace es el de la ejecución con algún flag (ver modo); nil = los códigos
+// salen sueltos, como en el main de siempre.
+var enlace *Enlace
+
+// Enlace es la conexión con el servidor cuando se usan sobres. Como los
+// actores del taller: una goroutine es la dueña de la conexión y de los
+// sobres pendientes, y el resto le habla por canales.
+type Enlace struct {
+	enviar  chan envio
+	esperar chan chan struct{}
+	caida   chan struct{}
+}
+
+type envio struct {
+	sobre sobres.Sobre
+	reply chan Confirmacion // nil = no se espera la confirmación del servidor
+}
+
+// Confirmacion es el resultado de enviar un Sobre al servidor.
+type Confirmacion struct {
+	Sobre sobres.Sobre
+	Err   error
+}
+
+type pendiente struct {
+	envio
+	limite    time.Time // para la confirmación del servidor
+	limiteAck time.Time
+	intentos  int
+
+	renovacion bool // reenvío del estado vigente para que no caduque
+}
+
+// vigente es el último estado enviado de un tema y cuándo toca renovarlo.
+type vigente struct {
+	sobre   sobres.Sobre
+	renovar time.Time
+}
+
+// nuevoEnlace arranca el enlace. Con ackTimeout 0 solo se espera la
+// confirmación del servidor, no los Ack de los talleres. Con lease, el
+// último estado enviado de cada tema se renueva cada tercio del lease
+// hasta que se llama a Esperar.
+func nuevoEnlace(conn net.Conn, emisor string, timeout, ackTimeout time.Duration, reintentos int, lease time.Duration) *Enlace {
+	e := &Enlace{
+		enviar:  make(chan envio),
+		esperar: make(chan chan struct{}),
+		caida:   make(chan struct{}),
+	}
+	go e.loop(conn, emisor, timeout, ackTimeout, reintentos, lease)
+	return e
+}
+
+// Enviar manda el sobre (el ID lo pone el enlace) y espera a que el
+// servidor lo confirme, se acabe el tiempo o se caiga la conexión. El Ack
+// de los talleres, si se espera, se sigue en segundo plano.
+func (e *Enlace) Enviar(s sobres.Sobre) Confirmacion {
+	reply := make(chan Confirmacion, 1)
+	e.enviar <- envio{sobre: s, reply: reply}
+	return <-reply
+}
+
+// Mandar manda el código en un sobre sin esperar a nada.
+func (e *Enlace) Mandar(code int) {
+	e.enviar <- envio{sobre: sobres.Sobre{Codigo: code}}
+}
+
+// Esperar bloquea hasta que no quede ningún sobre pendiente (con Ack, por
+// reintentos agotados o por desconexión).
+func (e *Enlace) Esperar() {
+	if e == nil {
+		return
+	}
+	done := make(chan struct{})
+	e.esperar <- done
+	<-done
+}
+
+// Caida se cierra cuando el servidor cierra la conexión.
+func (e *Enlace) Caida() <-chan struct{} {
+	return e.caida
+}
+
+func (e *Enlace) loop(conn net.Conn, emisor string, timeout, ackTimeout time.Duration, reintentos int, lease time.Duration) {
+	// Lo que llega del servidor: el broadcast de todos los mensajes, con las
+	// confirmaciones y los Ack de todas las mutuas; solo interesan los
+	// nuestros.
+	lineas := make(chan string)
+	go func() {
+		input := bufio.NewScanner(conn)
+		for input.Scan() {
+			lineas <- input.Text()
+		}
+		close(lineas)
+	}()
+
+	var id uint64
+	sesion := time.Now().UnixNano()
+	pendientes := map[uint64]*pendiente{}
+	resueltos := map[uint64]time.Time{} // id -> hasta cuándo se recuerda
+	var esperando []chan struct{}
+	conectado := true
+	// El último estado enviado de cada tema, que se renueva: en los talleres
+	// cada tema es una fuente con su propio lease.
+	vigentes := map[string]*vigente{}
+	acks, sinAck, obsoletos := 0, 0, 0
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+
+	escribir := func(p *pendiente) error {
+		data, _ := json.Marshal(p.sobre)
+		if _, err := fmt.Fprintf(conn, "%s\n", data); err != nil {
+			return err
+		}
+		if p.renovacion {
+			fmt.Fprintf(salidaEnlace, "Lease renovado: código %d (id %d)\n", p.sobre.Codigo, p.sobre.ID)
+		} else {
+			fmt.Fprintln(salidaEnlace, "Msg enviado: "+string(data))
+		}
+		p.intentos++
+		p.limite = time.Now().Add(timeout)
+		p.limiteAck = time.Now().Add(ackTimeout)
+		return nil
+	}
+	// Contesta a quien espera la confirmación del servidor (si alguien espera).
+	confirmar := func(p *pendiente, err error) {
+		if p.reply != nil {
+			p.reply <- Confirmacion{Sobre: p.sobre, Err: err}
+			p.reply = nil
+		}
+	}
+	despertar := func() {
+		if len(esperando) > 0 && ackTimeout > 0 {
+			fmt.Fprintf(salidaEnlace, "Códigos con ACK: %d (%d obsoletos), sin ACK: %d\n", acks, obsoletos, sinAck)
+		}
+		for _, done := range esperando {
+			close(done)
+		}
+		esperando = nil
+	}
+	terminar := func(id uint64) {
+		delete(pendientes, id)
+		if len(pendientes) == 0 {
+			despertar()
+		}
+	}
+	// resolver termina un sobre confirmado: los Ack que lleguen después
+	// para él se ignoran sin decir nada.
+	resolver := func(id uint64) {
+		resueltos[id] = time.Now().Add(recuerdoResueltos)
+		terminar(id)
+	}
+
+	for {
+		select {
+		case req := <-e.enviar:
+			id++
+			req.sobre.ID = id
+			req.sobre.Emisor, req.sobre.Sesion = emisor, sesion
+			req.sobre.Lease = lease
+			p := &pendiente{envio: req}
+			if !conectado {
+				confirmar(p, errDesconectado)
+				continue
+			}
+			if err := escribir(p); err != nil {
+				confirmar(p, err)
+				continue
+			}
+			pendientes[id] = p
+			if lease > 0 && esEstado(req.sobre.Codigo) {
+				vigentes[req.sobre.Tema] = &vigente{sobre: req.sobre, renovar: time.Now().Add(lease / 3)}
+			}
+
+		case done := <-e.esperar:
+			esperando = append(esperando, done)
+			if len(pendientes) == 0 {
+				despertar()
+			}
+
+		case linea, ok := <-lineas:
+			if !ok {
+				lineas = nil
+				conectado = false
+				close(e.caida)
+				if len(pendientes) > 0 {
+					log.Print(errDesconectado)
+				}
+				for id, p := range pendientes {
+					confirmar(p, errDesconectado)
+					if ackTimeout > 0 {
+						fmt.Fprintf(salidaEnlace, "SIN ACK: código %d (id %d), conexión cerrada\n", p.sobre.Codigo, id)
+						sinAck++
+					}
+					terminar(id)
+				}
+				continue
+			}
+
+			if de, n, esOK := sobres.AbrirOK(linea); esOK {
+				if p, hay := pendientes[n]; de == emisor && hay {
+					confirmar(p, nil)
+					if ackTimeout == 0 {
+						resolver(n)
+					}
+				}
+				continue
+			}
+
+			a, esAck := sobres.AbrirAck(linea)
+			if !esAck || a.Taller == "" || a.Origen != emisor {
+				continue
+			}
+			p, hay := pendientes[a.Ack]
+			if _, visto := resueltos[a.Ack]; !hay && visto {
+				continue // otro taller ya lo había confirmado
+			}
+			if !hay {
+				fmt.Fprintf(salidaEnlace, "ACK de %s: código %d (id %d), ya no se esperaba\n", a.Taller, a.Codigo, a.Ack)
+				continue
+			}
+			// Con que responda un taller basta. Un duplicado es que ya lo tenía
+			// (se perdió su primer Ack); un obsoleto ya no se aplicará.
+			switch {
+			case p.renovacion:
+			case a.Resultado == sobres.ResultadoObsoleto:
+				acks++
+				obsoletos++
+				fmt.Fprintf(salidaEnlace, "ACK de %s: código %d (id %d) DESCARTADO por llegar tarde, estado %s\n",
+					a.Taller, a.Codigo, a.Ack, a.Estado)
+			default:
+				acks++
+				fmt.Fprintf(salidaEnlace, "ACK de %s: código %d (id %d) %s a las %s, estado %s\n",
+					a.Taller, a.Codigo, a.Ack, a.Resultado, a.Aplicado.Format("15:04:05.000"), a.Estado)
+			}
+			confirmar(p, nil)
+			resolver(a.Ack)
+
+		case ahora := <-tick.C:
+			// Se renueva mientras nadie espera para cerrar.
+			for _, v := range vigentes {
+				if !conectado || len(esperando) > 0 || !ahora.After(v.renovar) {
+					continue
+				}
+				id++
+				p := &pendiente{envio: envio{sobre: v.sobre}, renovacion: true}
+				p.sobre.ID = id
+				if escribir(p) == nil {
+					pendientes[id] = p
+				}
+				v.renovar = ahora.Add(lease / 3)
+			}
+			for id, hasta := range resueltos {
+				if ahora.After(hasta) {
+					delete(resueltos, id)
+				}
+			}
+			for id, p := range pendientes {
+				if ahora.After(p.limite) {
+					confirmar(p, errSinConfirmar)
+					if ackTimeout == 0 {
+						terminar(id)
+						continue
+					}
+				}
+				if ackTimeout == 0 || !ahora.After(p.limiteAck) {
+					continue
+				}
+				if p.intentos <= reintentos {
+					fmt.Fprintf(salidaEnlace, "Sin ACK: código %d (id %d), reintento %d de %d\n", p.sobre.Codigo, id, p.intentos, reintentos)
+					if escribir(p) == nil {
+						continue
+					}
+				}
+				fmt.Fprintf(salidaEnlace, "SIN ACK: código %d (id %d) tras %d envíos\n", p.sobre.Codigo, id, p.intentos)
+				sinAck++
+				terminar(id)
+			}
+		}
+	}
+}
+
+// esEstado dice si el código cambia el estado del taller (los que caducan
+// con el lease); 7 y 8 no hacen nada y 10/11 son de los mecánicos.
+func esEstado(code int) bool {
+	return code >= 0 && code <= 9 && code != 7 && code != 8
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+// Los códigos sin confirmación (Mandar) también salen con el emisor, que es
+// la fuente que ven los talleres, y Esperar no se queda colgado si el
+// servidor no los confirma nunca.
+func TestEnlace_MandarSinConfirmar(t *testing.T) {
+	e, enviados, _ := servidorFalso(t, false)
+	e.Mandar(4)
+	if s := <-enviados; s.Codigo != 4 || s.Emisor != "mutua-prueba" || s.ID != 1 {
+		t.Fatalf("sobre enviado %+v", s)
+	}
+
+	hecho := make(chan struct{})
+	go func() {
+		e.Esperar()
+		close(hecho)
+	}()
+	select {
+	case <-hecho:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Esperar sigue esperando un sobre que nadie confirma")
+	}
+}
+
+// Todos los talleres responden a cada sobre. Con el primer Ack basta; los
+// de los demás no se anuncian como inesperados. Uno de un sobre que nunca
+// se envió, sí.
+func TestEnlace_AcksDeVariosTalleres(t *testing.T) {
+	out := &salida{}
+	salidaEnlace = out
+	defer func() { salidaEnlace = os.Stdout }()
+
+	mutua, srv := net.Pipe()
+	defer srv.Close()
+	go func() {
+		input := bufio.NewScanner(srv)
+		for input.Scan() {
+			s, ok := sobres.Abrir(input.Text())
+			if !ok {
+				continue
+			}
+			fmt.Fprintln(srv, sobres.OK(s.Emisor, s.ID))
+			for _, taller := range []string{"127.0.0.1:5001", "127.0.0.1:5002", "127.0.0.1:5003"} {
+				data, _ := json.Marshal(sobres.Ack{Ack: s.ID, Codigo: s.Codigo, Origen: s.Emisor,
+					Taller: taller, Resultado: sobres.ResultadoAplicado})
+				fmt.Fprintf(srv, "%s\n", data)
+			}
+			// Y uno que no es de nada que se haya enviado.
+			data, _ := json.Marshal(sobres.Ack{Ack: 99, Origen: s.Emisor, Taller: "127.0.0.1:5001"})
+			fmt.Fprintf(srv, "%s\n", data)
+		}
+	}()
+
+	e := nuevoEnlace(mutua, "mutua-prueba", time.Second, time.Second, 0, 0)
+	e.Mandar(4)
+	e.Esperar()
+	for limite := time.Now().Add(5 * time.Second); !strings.Contains(out.String(), "(id 99)"); {
+		if time.Now().After(limite) {
+			t.Fatalf("no avisa del Ack inesperado:\n%s", out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := strings.Count(out.String(), "ACK de"); n != 2 {
+		t.Fatalf("%d líneas de ACK, quería la del primer taller y la del id 99:\n%s", n, out)
+	}
+	if !strings.Contains(out.String(), "Códigos con ACK: 1 (0 obsoletos), sin ACK: 0") {
+		t.Fatalf("resumen:\n%s", out)
+	}
+}
+
+// Con lease, cada tema es una fuente en los talleres y su estado caduca
+// Lease después del último sobre de ese tema que llega (como hace el
+// controlador del taller). Pasado el lease, los dos temas siguen vigentes
+// porque se renueva cada uno, no solo el último enviado.
+func TestEnlace_LeasePorTema(t *testing.T) {
+	const lease = 300 * time.Millisecond
+	mutua, srv := net.Pipe()
+	defer srv.Close()
+	llegan := make(chan sobres.Sobre, 100)
+	go func() {
+		input := bufio.NewScanner(srv)
+		for input.Scan() {
+			if s, ok := sobres.Abrir(input.Text()); ok {
+				llegan <- s
+				go fmt.Fprintln(srv, sobres.OK(s.Emisor, s.ID))
+			}
+		}
+	}()
+
+	e := nuevoEnlace(mutua, "mutua-prueba", time.Second, 0, 0, lease)
+	for tema, code := range map[string]int{"turnos": 3, "inventario": 5} {
+		if c := e.Enviar(sobres.Sobre{Codigo: code, Tema: tema}); c.Err != nil {
+			t.Fatal(c.Err)
+		}
+	}
+
+	hasta := map[string]time.Time{} // fuente -> cuándo caduca su estado
+	codigo := map[string]int{}
+	for fin := time.After(4 * lease); ; {
+		select {
+		case s := <-llegan:
+			if s.Lease != lease {
+				t.Fatalf("sobre sin el lease: %+v", s)
+			}
+			fuente := s.Emisor + "/" + s.Tema
+			hasta[fuente], codigo[fuente] = time.Now().Add(s.Lease), s.Codigo
+			continue
+		case <-fin:
+		}
+		break
+	}
+	ahora := time.Now()
+	for fuente, code := range map[string]int{"mutua-prueba/turnos": 3, "mutua-prueba/inventario": 5} {
+		if !hasta[fuente].After(ahora) || codigo[fuente] != code {
+			t.Errorf("%s: código %d hasta %v, quería el %d vigente", fuente, codigo[fuente], hasta[fuente].Sub(ahora), code)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var escenarioPath = flag.String("escenario", "", "fichero de escenario con los códigos a enviar; vacío = códigos aleatorios")
+
+// Tipos de paso de un escenario.
+const (
+	PasoEnviar = iota // espera Espera y envía Codigo
+	PasoEsperar
+	PasoIr // salta a Destino (Veces veces; 0 = siempre)
+)
+
+// Paso es una instrucción del escenario.
+type Paso struct {
+	Tipo    int
+	Espera  time.Duration
+	Codigo  int
+	Destino int // índice del paso al que salta PasoIr
+	Veces   int
+	Linea   int
+}
+
+// Escenario es una secuencia de pasos. Formato, una instrucción por línea
+// (lo que va tras '#' es comentario):
+//
+//	etiqueta:            marca este punto
+//	<espera> <código>    espera y envía el código (0..11); 30s, 1m o 5 (segundos)
+//	espera <espera>      solo espera
+//	ir <etiqueta> [N]    vuelve a la etiqueta N veces más (sin N, para siempre)
+type Escenario struct {
+	Pasos []Paso
+}
+
+// CargarEscenario lee y valida un escenario.
+func CargarEscenario(path string) (*Escenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LeerEscenario(bufio.NewScanner(f), path)
+}
+
+// LeerEscenario lee un escenario línea a línea; nombre es para los errores.
+func LeerEscenario(input *bufio.Scanner, nombre string) (*Escenario, error) {
+	e := &Escenario{}
+	etiquetas := map[string]int{}
+	saltos := map[int]string{} // paso -> etiqueta a resolver al final
+
+	for n := 1; input.Scan(); n++ {
+		linea, _, _ := strings.Cut(input.Text(), "#")
+		campos := strings.Fields(linea)
+		fallo := func(format string, args ...any) error {
+			return fmt.Errorf("%s:%d: %s", nombre, n, fmt.Sprintf(format, args...))
+		}
+
+		switch {
+		case len(campos) == 0:
+			continue
+
+		case len(campos) == 1 && strings.HasSuffix(campos[0], ":"):
+			etiqueta := strings.TrimSuffix(campos[0], ":")
+			if _, ok := etiquetas[etiqueta]; ok {
+				return nil, fallo("etiqueta %q repetida", etiqueta)
+			}
+			etiquetas[etiqueta] = len(e.Pasos)
+
+		case campos[0] == "ir":
+			if len(campos) < 2 || len(campos) > 3 {
+				return nil, fallo("uso: ir <etiqueta> [veces]")
+			}
+			p := Paso{Tipo: PasoIr, Linea: n}
+			if len(campos) == 3 {
+				v, err := strconv.Atoi(campos[2])
+				if err != nil || v < 1 {
+					return nil, fallo("veces no válidas: %q", campos[2])
+				}
+				p.Veces = v
+			}
+			saltos[len(e.Pasos)] = campos[1]
+			e.Pasos = append(e.Pasos, p)
+
+		case campos[0] == "espera":
+			if len(campos) != 2 {
+				return nil, fallo("uso: espera <tiempo>")
+			}
+			d, err := leerEspera(campos[1])
+			if err != nil {
+				return nil, fallo("%v", err)
+			}
+			e.Pasos = append(e.Pasos, Paso{Tipo: PasoEsperar, Espera: d, Linea: n})
+
+		case len(campos) == 2:
+			d, err := leerEspera(campos[0])
+			if err != nil {
+				return nil, fallo("%v", err)
+			}
+			code, err := strconv.Atoi(campos[1])
+			if err != nil || code < 0 || code > 11 {
+				return nil, fallo("código no válido: %q (0..11)", campos[1])
+			}
+			e.Pasos = append(e.Pasos, Paso{Tipo: PasoEnviar, Espera: d, Codigo: code, Linea: n})
+
+		default:
+			return nil, fallo("no se entiende %q", strings.TrimSpace(linea))
+		}
+	}
+	if err := input.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, etiqueta := range saltos {
+		destino, ok := etiquetas[etiqueta]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: etiqueta %q no definida", nombre, e.Pasos[i].Linea, etiqueta)
+		}
+		e.Pasos[i].Destino = destino
+	}
+	if p, ok := e.bucleSinEspera(); ok {
+		return nil, fmt.Errorf("%s:%d: \"ir\" sin fin a un bucle que no espera nunca (enviaría sin parar)", nombre, p.Linea)
+	}
+	return e, nil
+}
+
+// bucleSinEspera busca un "ir" sin N que puede volver a sí mismo sin pasar
+// por ningún paso que espere: Recorrer se quedaría enviando sin parar. Un
+// "ir" con N puede saltar o seguir, así que cuenta por los dos caminos.
+func (e *Escenario) bucleSinEspera() (Paso, bool) {
+	siguientes := func(pc int) []int {
+		p := e.Pasos[pc]
+		switch {
+		case p.Tipo != PasoIr:
+			return []int{pc + 1}
+		case p.Veces == 0:
+			return []int{p.Destino}
+		}
+		return []int{p.Destino, pc + 1}
+	}
+
+	for i, p := range e.Pasos {
+		if p.Tipo != PasoIr || p.Veces != 0 {
+			continue
+		}
+		visto := map[int]bool{}
+		pendientes := []int{p.Destino}
+		for len(pendientes) > 0 {
+			pc := pendientes[len(pendientes)-1]
+			pendientes = pendientes[:len(pendientes)-1]
+			if pc == i {
+				return p, true
+			}
+			if pc >= len(e.Pasos) || visto[pc] || e.Pasos[pc].Espera > 0 {
+				continue
+			}
+			visto[pc] = true
+			pendientes = append(pendientes, siguientes(pc)...)
+		}
+	}
+	return Paso{}, false
+}
+
+// leerEspera admite una duración de Go (30s, 1m30s) o segundos enteros.
+func leerEspera(s string) (time.Duration, error) {
+	if seg, err := strconv.Atoi(s); err == nil && seg >= 0 {
+		return time.Duration(seg) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("espera no válida: %q", s)
+	}
+	return d, nil
+}
+
+// Recorrer ejecuta el escenario llamando a esperar y enviar en orden.
+func (e *Escenario) Recorrer(esperar func(time.Duration), enviar func(int)) {
+	vueltas := map[int]int{} // paso ir -> saltos que le quedan
+	for pc := 0; pc < len(e.Pasos); {
+		p := e.Pasos[pc]
+		switch p.Tipo {
+		case PasoEnviar:
+			esperar(p.Espera)
+			enviar(p.Codigo)
+		case PasoEsperar:
+			esperar(p.Espera)
+		case PasoIr:
+			if p.Veces == 0 {
+				pc = p.Destino
+				continue
+			}
+			quedan, ok := vueltas[pc]
+			if !ok {
+				quedan = p.Veces
+			}
+			if quedan > 0 {
+				vueltas[pc] = quedan - 1
+				pc = p.Destino
+				continue
+			}
+			// Bucle terminado: si se vuelve a llegar a él, cuenta de nuevo.
+			delete(vueltas, pc)
+		}
+		pc++
+	}
+}
+
+// ejecutarEscenario envía por dst los códigos del escenario de -escenario.
+func ejecutarEscenario(dst net.Conn) {
+	e, err := CargarEscenario(*escenarioPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Escenario " + *escenarioPath + " en: " + dst.RemoteAddr().String())
+	e.Recorrer(time.Sleep, func(code int) {
+		setSeparator()
+		enviarCodigo(dst, code)
+	})
+	fmt.Println("Escenario terminado en: " + dst.RemoteAddr().String())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func leer(t *testing.T, texto string) (*Escenario, error) {
+	t.Helper()
+	return LeerEscenario(bufio.NewScanner(strings.NewReader(texto)), "prueba")
+}
+
+// Las esperas y los códigos salen en orden, los bucles con N se repiten N
+// veces más (también anidados) y se vuelven a contar al llegar otra vez.
+func TestEscenario_Recorrer(t *testing.T) {
+	e, err := leer(t, `
+		# apertura
+		0 2          # espera 0 y envía SOLO B
+		fuera:
+		1s 4
+		dentro:
+		espera 500ms
+		ir dentro 1
+		2 9
+		ir fuera 1   # la vuelta de fuera repite dentro otra vez
+		1m30s 0
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var traza []string
+	e.Recorrer(
+		func(d time.Duration) { traza = append(traza, d.String()) },
+		func(code int) { traza = append(traza, fmt.Sprint("->", code)) },
+	)
+	vuelta := []string{"1s", "->4", "500ms", "500ms", "2s", "->9"}
+	want := append(append(append([]string{"0s", "->2"}, vuelta...), vuelta...), "1m30s", "->0")
+	if fmt.Sprint(traza) != fmt.Sprint(want) {
+		t.Fatalf("traza = %v\nquería   %v", traza, want)
+	}
+}
+
+// Un "ir" sin N vuelve siempre: Recorrer no acaba (se corta desde enviar).
+func TestEscenario_RecorrerSinFin(t *testing.T) {
+	e, err := leer(t, "bucle:\n1s 3\nir bucle\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type basta struct{}
+	enviados := 0
+	defer func() {
+		if r := recover(); r != (basta{}) {
+			t.Fatalf("recover = %v", r)
+		}
+		if enviados != 100 {
+			t.Fatalf("enviados %d", enviados)
+		}
+	}()
+	e.Recorrer(func(time.Duration) {}, func(int) {
+		if enviados++; enviados == 100 {
+			panic(basta{})
+		}
+	})
+	t.Fatal("Recorrer ha terminado con un ir sin fin")
+}
+
+func TestLeerEscenario_Errores(t *testing.T) {
+	for _, tc := range []struct {
+		texto string
+		error string
+	}{
+		{"1s 12", "prueba:1: código no válido"},
+		{"1s x", "prueba:1: código no válido"},
+		{"-1s 3", "prueba:1: espera no válida"},
+		{"espera", "prueba:1: uso: espera"},
+		{"a:\n\na:", "prueba:3: etiqueta \"a\" repetida"},
+		{"ir", "prueba:1: uso: ir"},
+		{"a:\nir a 0", "prueba:2: veces no válidas"},
+		{"1s 3\nir nada", "prueba:2: etiqueta \"nada\" no definida"},
+		{"hola que tal", "prueba:1: no se entiende"},
+		// Bucles sin fin que no esperan nunca.
+		{"a:\nir a", "prueba:2: \"ir\" sin fin"},
+		{"a:\n0 3\nespera 0\nir a", "prueba:4: \"ir\" sin fin"},
+		{"a:\nb:\n0 3\nir b 2\nir a", "prueba:5: \"ir\" sin fin"},
+		{"a:\nir b\n1s 3\nb:\nir a", "prueba:2: \"ir\" sin fin"}, // el salto se salta la espera
+	} {
+		if _, err := leer(t, tc.texto); err == nil || !strings.Contains(err.Error(), tc.error) {
+			t.Errorf("%q: err = %v, quería %q", tc.texto, err, tc.error)
+		}
+	}
+
+	// Con una espera dentro, o con N, el bucle acaba o va a su ritmo.
+	for _, texto := range []string{
+		"a:\n1s 3\nir a",
+		"a:\n0 3\nespera 1\nir a",
+		"a:\n0 3\nir a 5",
+		"a:\nir b\nb:\n1s 2\nir a",
+		"ir fin\nfin:",
+	} {
+		if _, err := leer(t, texto); err != nil {
+			t.Errorf("%q: %v", texto, err)
+		}
+	}
+}
+
+// El escenario de ejemplo del repositorio se carga.
+func TestCargarEscenario_Ejemplo(t *testing.T) {
+	if _, err := CargarEscenario("escenarios/solo_c_cerrado.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	generadorNombre = flag.String("generador", "", "generador de códigos: uniforme, ponderado o markov; vacío = el aleatorio original")
+	generadorConfig = flag.String("config", "", "fichero JSON con los pesos (ponderado) o la matriz de transición y estancias (markov)")
+	semilla         = flag.Int64("semilla", 0, "semilla del generador (0 también vale); sin -semilla, una distinta en cada ejecución")
+	pasos           = flag.Int("pasos", 10, "número de códigos a enviar entre el 0 inicial y el final")
+)
+
+// Generador decide el siguiente código a enviar y cuánto se mantiene
+// antes de enviar el siguiente.
+type Generador interface {
+	Siguiente() (codigo int, espera time.Duration)
+}
+
+// Estancia es una distribución del tiempo en un estado, en segundos.
+// Tipo: "fija" (Valor), "uniforme" (Min..Max) o "exponencial" (Media).
+type Estancia struct {
+	Tipo  string  `json:"tipo"`
+	Valor float64 `json:"valor,omitempty"`
+	Min   float64 `json:"min,omitempty"`
+	Max   float64 `json:"max,omitempty"`
+	Media float64 `json:"media,omitempty"`
+}
+
+// estanciaOriginal son los 1..9 s de la mutua original.
+var estanciaOriginal = Estancia{Tipo: "uniforme", Min: 1, Max: 9}
+
+func (e Estancia) validar() error {
+	switch e.Tipo {
+	case "fija":
+		if e.Valor < 0 {
+			return fmt.Errorf("estancia fija negativa")
+		}
+	case "uniforme":
+		if e.Min < 0 || e.Max < e.Min {
+			return fmt.Errorf("estancia uniforme: hace falta 0 <= min <= max")
+		}
+	case "exponencial":
+		if e.Media <= 0 {
+			return fmt.Errorf("estancia exponencial: la media debe ser > 0")
+		}
+	default:
+		return fmt.Errorf("estancia: tipo %q desconocido (fija, uniforme, exponencial)", e.Tipo)
+	}
+	return nil
+}
+
+func (e Estancia) muestra(rng *rand.Rand) time.Duration {
+	var seg float64
+	switch e.Tipo {
+	case "fija":
+		seg = e.Valor
+	case "uniforme":
+		seg = e.Min + rng.Float64()*(e.Max-e.Min)
+	case "exponencial":
+		seg = rng.ExpFloat64() * e.Media
+	}
+	return time.Duration(seg * float64(time.Second))
+}
+
+// GeneradorConfig es el fichero de -config. Las claves de los mapas son
+// códigos ("0".."11").
+type GeneradorConfig struct {
+	Pesos map[string]float64 `json:"pesos,omitempty"` // ponderado: peso de cada código
+
+	Inicial      int                           `json:"inicial,omitempty"`      // markov: estado de partida
+	Transiciones map[string]map[string]float64 `json:"transiciones,omitempty"` // markov: de -> a -> peso
+	Estancias    map[string]Estancia           `json:"estancias,omitempty"`    // markov: tiempo en cada estado
+
+	Estancia *Estancia `json:"estancia,omitempty"` // la de los códigos sin una propia (por defecto 1..9 s)
+}
+
+// Uniforme elige cualquier código 0..9 con la misma probabilidad.
+type Uniforme struct {
+	rng      *rand.Rand
+	estancia Estancia
+}
+
+func (g *Uniforme) Siguiente() (int, time.Duration) {
+	return g.rng.Intn(10), g.estancia.muestra(g.rng)
+}
+
+// Ponderado elige cada código con probabilidad proporcional a su peso.
+type Ponderado struct {
+	rng      *rand.Rand
+	pesos    map[int]float64
+	estancia Estancia
+}
+
+func (g *Ponderado) Siguiente() (int, time.Duration) {
+	return elegir(g.rng, g.pesos), g.estancia.muestra(g.rng)
+}
+
+// Markov es una cadena de Markov: el siguiente código depende del actual
+// según la matriz de transición, y cada estado dura según su estancia.
+type Markov struct {
+	rng          *rand.Rand
+	actual       int
+	empezado     bool
+	transiciones map[int]map[int]float64
+	estancias    map[int]Estancia
+	estancia     Estancia
+}
+
+func (g *Markov) Siguiente() (int, time.Duration) {
+	if g.empezado {
+		g.actual = elegir(g.rng, g.transiciones[g.actual])
+	}
+	g.empezado = true
+	e, ok := g.estancias[g.actual]
+	if !ok {
+		e = g.estancia
+	}
+	return g.actual, e.muestra(g.rng)
+}
+
+// elegir saca un código con probabilidad proporcional a su peso. Recorre los
+// códigos en orden para que una misma semilla dé siempre la misma secuencia.
+func elegir(rng *rand.Rand, pesos map[int]float64) int {
+	codigos := make([]int, 0, len(pesos))
+	total := 0.0
+	for c, p := range pesos {
+		codigos = append(codigos, c)
+		total += p
+	}
+	sort.Ints(codigos)
+	x := rng.Float64() * total
+	for _, c := range codigos {
+		if x < pesos[c] {
+			return c
+		}
+		x -= pesos[c]
+	}
+	return codigos[len(codigos)-1]
+}
+
+// NuevoGenerador crea el generador pedido con su configuración (puede ser
+// la vacía para uniforme).
+func NuevoGenerador(nombre string, cfg GeneradorConfig, semilla int64) (Generador, error) {
+	rng := rand.New(rand.NewSource(semilla))
+	estancia := estanciaOriginal
+	if cfg.Estancia != nil {
+		if err := cfg.Estancia.validar(); err != nil {
+			return nil, err
+		}
+		estancia = *cfg.Estancia
+	}
+
+	switch nombre {
+	case "uniforme":
+		return &Uniforme{rng: rng, estancia: estancia}, nil
+
+	case "ponderado":
+		pesos, err := leerPesos(cfg.Pesos)
+		if err != nil {
+			return nil, fmt.Errorf("pesos: %v", err)
+		}
+		return &Ponderado{rng: rng, pesos: pesos, estancia: estancia}, nil
+
+	case "markov":
+		g := &Markov{rng: rng, actual: cfg.Inicial, transiciones: map[int]map[int]float64{}, estancias: map[int]Estancia{}, estancia: estancia}
+		for de, fila := range cfg.Transiciones {
+			c, err := leerCodigo(de)
+			if err != nil {
+				return nil, fmt.Errorf("transiciones: %v", err)
+			}
+			if g.transiciones[c], err = leerPesos(fila); err != nil {
+				return nil, fmt.Errorf("transiciones desde %d: %v", c, err)
+			}
+		}
+		// Todo estado al que se pueda llegar necesita su fila.
+		if _, ok := g.transiciones[g.actual]; !ok {
+			return nil, fmt.Errorf("transiciones: falta la fila del estado inicial %d", g.actual)
+		}
+		for de, fila := range g.transiciones {
+			for a := range fila {
+				if _, ok := g.transiciones[a]; !ok {
+					return nil, fmt.Errorf("transiciones: %d lleva a %d, que no tiene fila", de, a)
+				}
+			}
+		}
+		for k, e := range cfg.Estancias {
+			c, err := leerCodigo(k)
+			if err != nil {
+				return nil, fmt.Errorf("estancias: %v", err)
+			}
+			if err := e.validar(); err != nil {
+				return nil, fmt.Errorf("estancias de %d: %v", c, err)
+			}
+			g.estancias[c] = e
+		}
+		return g, nil
+	}
+	return nil, fmt.Errorf("generador %q desconocido (uniforme, ponderado, markov)", nombre)
+}
+
+func leerCodigo(s string) (int, error) {
+	c, err := strconv.Atoi(s)
+	if err != nil || c < 0 || c > 11 {
+		return 0, fmt.Errorf("código no válido: %q (0..11)", s)
+	}
+	return c, nil
+}
+
+// leerPesos convierte {"código": peso} comprobando que algún peso sea > 0.
+func leerPesos(m map[string]float64) (map[int]float64, error) {
+	pesos := map[int]float64{}
+	total := 0.0
+	for k, p := range m {
+		c, err := leerCodigo(k)
+		if err != nil {
+			return nil, err
+		}
+		if p < 0 {
+			return nil, fmt.Errorf("peso negativo para %d", c)
+		}
+		if p > 0 {
+			pesos[c] = p
+			total += p
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("hace falta algún peso > 0")
+	}
+	return pesos, nil
+}
+
+// original es el aleatorio de mutua.go (getRand) como Generador.
+type original struct{}
+
+func (original) Siguiente() (int, time.Duration) {
+	return getRand(), time.Duration(getRand()+1) * time.Second
+}
+
+// generadorFlags crea el generador de -generador, o el original si no se pidió.
+func generadorFlags() Generador {
+	if *generadorNombre == "" {
+		return original{}
+	}
+	var cfg GeneradorConfig
+	if *generadorConfig != "" {
+		data, err := os.ReadFile(*generadorConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			log.Fatal(fmt.Errorf("%s: %v", *generadorConfig, err))
+		}
+	}
+	s := *semilla
+	if !flagPuesto("semilla") {
+		s = time.Now().UnixNano()
+	}
+	g, err := NuevoGenerador(*generadorNombre, cfg, s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Generador " + *generadorNombre + ", semilla " + strconv.FormatInt(s, 10))
+	return g
+}
+
+// flagPuesto dice si el flag se ha dado en la línea de órdenes (aunque sea
+// con su valor por defecto).
+func flagPuesto(nombre string) bool {
+	puesto := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == nombre {
+			puesto = true
+		}
+	})
+	return puesto
+}
+
+// operandoCon es operando con el código y la espera que dé el generador.
+func operandoCon(dst net.Conn, g Generador) {
+	setSeparator()
+	codigo, espera := g.Siguiente()
+	enviarCodigo(dst, codigo)
+	fmt.Println("Operando en: " + dst.RemoteAddr().String())
+	fmt.Println("Tiempo: " + espera.Round(time.Millisecond).String())
+	time.Sleep(espera)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func leerConfig(t *testing.T, path string) GeneradorConfig {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg GeneradorConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func secuencia(t *testing.T, nombre string, cfg GeneradorConfig, semilla int64, n int) []string {
+	t.Helper()
+	g, err := NuevoGenerador(nombre, cfg, semilla)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for i := 0; i < n; i++ {
+		c, d := g.Siguiente()
+		out = append(out, fmt.Sprint(c, "/", d))
+	}
+	return out
+}
+
+// Con la misma semilla, cada generador repite la secuencia de códigos y
+// esperas (también con semilla 0); con otra, cambia.
+func TestGenerador_Semilla(t *testing.T) {
+	for nombre, cfg := range map[string]GeneradorConfig{
+		"uniforme":  {},
+		"ponderado": leerConfig(t, "generadores/ponderado.json"),
+		"markov":    leerConfig(t, "generadores/markov.json"),
+	} {
+		for _, s := range []int64{0, 42} {
+			a, b := secuencia(t, nombre, cfg, s, 50), secuencia(t, nombre, cfg, s, 50)
+			if fmt.Sprint(a) != fmt.Sprint(b) {
+				t.Errorf("%s semilla %d: %v y %v", nombre, s, a, b)
+			}
+		}
+		if fmt.Sprint(secuencia(t, nombre, cfg, 1, 50)) == fmt.Sprint(secuencia(t, nombre, cfg, 2, 50)) {
+			t.Errorf("%s: semillas 1 y 2 dan lo mismo", nombre)
+		}
+	}
+}
+
+// -semilla 0 se respeta; sin -semilla, cada ejecución usa una distinta.
+func TestFlagPuesto(t *testing.T) {
+	defer func(s int64) { *semilla = s }(*semilla)
+	if flagPuesto("semilla") {
+		t.Fatal("semilla puesta sin darla")
+	}
+	if err := flag.Set("semilla", "0"); err != nil {
+		t.Fatal(err)
+	}
+	if !flagPuesto("semilla") || *semilla != 0 {
+		t.Fatalf("-semilla 0: puesto %v, valor %d", flagPuesto("semilla"), *semilla)
+	}
+}
+
+// elegir saca cada código en proporción a su peso.
+func TestElegir_Proporcion(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	pesos := map[int]float64{1: 1, 4: 3, 9: 6}
+	const n = 100000
+	veces := map[int]int{}
+	for i := 0; i < n; i++ {
+		veces[elegir(rng, pesos)]++
+	}
+	for c, p := range pesos {
+		if got, want := float64(veces[c])/n, p/10; got < want-0.01 || got > want+0.01 {
+			t.Errorf("código %d: %.3f de las veces, quería %.2f", c, got, want)
+		}
+	}
+	if len(veces) != len(pesos) {
+		t.Errorf("salen códigos sin peso: %v", veces)
+	}
+}
+
+// Los pesos 0 no salen nunca; sin ningún peso > 0, o con uno negativo o un
+// código fuera de 0..11, no hay generador.
+func TestPonderado_Pesos(t *testing.T) {
+	g, err := NuevoGenerador("ponderado", GeneradorConfig{Pesos: map[string]float64{"3": 1, "9": 0}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if c, _ := g.Siguiente(); c != 3 {
+			t.Fatalf("sale %d con peso 0", c)
+		}
+	}
+
+	for _, tc := range []struct {
+		pesos map[string]float64
+		error string
+	}{
+		{map[string]float64{"3": 0}, "algún peso > 0"},
+		{map[string]float64{}, "algún peso > 0"},
+		{map[string]float64{"3": -1}, "peso negativo"},
+		{map[string]float64{"12": 1}, "código no válido"},
+		{map[string]float64{"x": 1}, "código no válido"},
+	} {
+		if _, err := NuevoGenerador("ponderado", GeneradorConfig{Pesos: tc.pesos}, 1); err == nil || !strings.Contains(err.Error(), tc.error) {
+			t.Errorf("%v: err = %v, quería %q", tc.pesos, err, tc.error)
+		}
+	}
+}
+
+// La cadena empieza en el inicial, solo sigue transiciones con peso y cada
+// estado dura lo que dice su estancia (o la común).
+func TestMarkov_Transiciones(t *testing.T) {
+	cfg := leerConfig(t, "generadores/markov.json")
+	g, err := NuevoGenerador("markov", cfg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anterior := -1
+	for i := 0; i < 1000; i++ {
+		c, d := g.Siguiente()
+		if i == 0 && c != cfg.Inicial {
+			t.Fatalf("empieza en %d, quería %d", c, cfg.Inicial)
+		}
+		if i > 0 && cfg.Transiciones[fmt.Sprint(anterior)][fmt.Sprint(c)] <= 0 {
+			t.Fatalf("paso %d: de %d a %d sin transición", i, anterior, c)
+		}
+		if c == 9 && d != 10*time.Second {
+			t.Fatalf("CERRADO dura %v, quería la estancia fija de 10s", d)
+		}
+		if (c == 2 || c == 3) && (d < 3*time.Second || d > 6*time.Second) {
+			t.Fatalf("%d dura %v, fuera de 3..6s", c, d)
+		}
+		anterior = c
+	}
+}
+
+func TestMarkov_Validacion(t *testing.T) {
+	fija := Estancia{Tipo: "fija", Valor: 1}
+	for _, tc := range []struct {
+		cfg   GeneradorConfig
+		error string
+	}{
+		{GeneradorConfig{Inicial: 4, Transiciones: map[string]map[string]float64{"5": {"5": 1}}}, "falta la fila del estado inicial 4"},
+		{GeneradorConfig{Inicial: 4, Transiciones: map[string]map[string]float64{"4": {"5": 1}}}, "4 lleva a 5, que no tiene fila"},
+		{GeneradorConfig{Transiciones: map[string]map[string]float64{"0": {"0": 0}}}, "transiciones desde 0: hace falta algún peso > 0"},
+		{GeneradorConfig{Transiciones: map[string]map[string]float64{"0": {"0": -1}}}, "transiciones desde 0: peso negativo"},
+		{GeneradorConfig{Transiciones: map[string]map[string]float64{"A": {"0": 1}}}, "transiciones: código no válido"},
+		{GeneradorConfig{Transiciones: map[string]map[string]float64{"0": {"0": 1}}, Estancias: map[string]Estancia{"0": {Tipo: "normal"}}}, "estancias de 0: estancia: tipo \"normal\" desconocido"},
+		{GeneradorConfig{Transiciones: map[string]map[string]float64{"0": {"0": 1}}, Estancias: map[string]Estancia{"0": {Tipo: "uniforme", Min: 5, Max: 1}}}, "estancias de 0: estancia uniforme"},
+		{GeneradorConfig{Transiciones: map[string]map[string]float64{"0": {"0": 1}}, Estancias: map[string]Estancia{"0": {Tipo: "exponencial"}}}, "la media debe ser > 0"},
+		{GeneradorConfig{Transiciones: map[string]map[string]float64{"0": {"0": 1}}, Estancias: map[string]Estancia{"13": fija}}, "estancias: código no válido"},
+	} {
+		if _, err := NuevoGenerador("markov", tc.cfg, 1); err == nil || !strings.Contains(err.Error(), tc.error) {
+			t.Errorf("%+v: err = %v, quería %q", tc.cfg, err, tc.error)
+		}
+	}
+	if _, err := NuevoGenerador("poisson", GeneradorConfig{}, 1); err == nil {
+		t.Error("generador desconocido sin error")
+	}
+}
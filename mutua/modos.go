@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// init elige el modo antes del main de mutua.go, que no se toca. Sin flags,
+// main hace lo de siempre: un 0, diez códigos aleatorios y otro 0. Con
+// algún flag, el modo lo lleva modo y el proceso termina aquí, sin pasar
+// por main. En los tests no se hace nada (los flags son los de go test).
+func init() {
+	if testing.Testing() {
+		return
+	}
+	flag.Parse()
+	if flag.NFlag() == 0 {
+		return
+	}
+	modo()
+	os.Exit(0)
+}
+
+// modo conecta con el servidor y ejecuta el modo que piden los flags:
+// escenario, demonio, consola o el generador de códigos. Los códigos van en
+// sobres por el enlace.
+func modo() {
+	gen := generadorFlags()
+	conn, err := net.Dial("tcp", "localhost:8000")
+	if err != nil {
+		logger.Fatal(err)
+	}
+	// Siempre en sobres: con el emisor, cada mutua es una fuente distinta en
+	// los talleres. Los Ack de los talleres solo se esperan con -ack.
+	t := time.Duration(0)
+	if *ackOn {
+		t = *ackTimeout
+	}
+	nombre := *emisor
+	if nombre == "" {
+		nombre = "mutua-" + strconv.Itoa(os.Getpid())
+	}
+	enlace = nuevoEnlace(conn, nombre, *confTimeout, t, *reintentos, *lease)
+	switch {
+	case *escenarioPath != "":
+		ejecutarEscenario(conn)
+	case *httpAddr != "":
+		servirHTTP(*httpAddr, enlace)
+		return
+	case *consolaOn:
+		consola(conn)
+	default:
+		iniciarCon(conn)
+		for i := 0; i < *pasos; i++ {
+			operandoCon(conn, gen)
+		}
+		terminarCon(conn)
+	}
+	enlace.Esperar()
+	conn.Close()
+}
+
+// enviarCodigo es Send2conn, o un sobre si se usa el enlace.
+func enviarCodigo(dst net.Conn, code int) {
+	if enlace != nil {
+		enlace.Mandar(code)
+		return
+	}
+	Send2conn(dst, code)
+}
+
+// iniciarCon y terminarCon son iniciar y terminar con enviarCodigo.
+func iniciarCon(dst net.Conn) {
+	time.Sleep(1 * time.Second)
+	setSeparator()
+	fmt.Println("Iniciando operación en: " + dst.RemoteAddr().String())
+	enviarCodigo(dst, 0)
+	time.Sleep(1 * time.Second)
+}
+
+func terminarCon(dst net.Conn) {
+	setSeparator()
+	enviarCodigo(dst, 0)
+	time.Sleep(1 * time.Second)
+	fmt.Println("Terminando operación en: " + dst.RemoteAddr().String())
+}
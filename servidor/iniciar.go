@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+// init arranca, antes del main de servidor.go (que no se toca), lo que no
+// es del broadcast: aplica los flags, programa el replay de -replay y lanza
+// el confirmador de sobres y la consola de administración, que atiende por
+// la entrada estándar la orden
+//
+//	replay fichero [velocidad]
+//
+// El confirmador es un cliente más del broadcaster: se apunta por entering
+// y recibe todo lo que se reparte. Con -journal, además, las conexiones
+// las atiende servir en vez de main: handleConn no dice quién manda cada
+// línea y el journal lo anota. En los tests no se hace nada (los flags son
+// los de go test).
+func init() {
+	if testing.Testing() {
+		return
+	}
+	flag.Parse()
+	if *journalPath != "" {
+		j, err := abrirJournal(*journalPath, messages)
+		if err != nil {
+			log.Fatal(err)
+		}
+		journal = j
+	}
+	if *replayPath != "" {
+		go func() {
+			time.Sleep(*esperaRep)
+			if err := reproducir(*replayPath, *velocidad); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+	go confirmador()
+	go consola()
+	if journal != nil {
+		servir() // no vuelve: main no llega a arrancar
+	}
+}
+
+// servir es el main de servidor.go con atender en vez de handleConn.
+func servir() {
+	listener, err := net.Listen("tcp", "localhost:8000")
+	if err != nil {
+		log.Fatal(err)
+	}
+	go broadcaster()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		go atender(conn)
+	}
+}
+
+// atender es handleConn, pero reparte cada línea por repartir con la
+// dirección de la conexión, para que el journal sepa quién la mandó.
+func atender(conn net.Conn) {
+	ch := make(chan string)
+	go clientWriter(conn, ch)
+	who := conn.RemoteAddr().String()
+	ch <- "Taller localizado en " + who
+	repartir(who, who+" Se ha conectado")
+	entering <- ch
+	input := bufio.NewScanner(conn)
+	for input.Scan() {
+		repartir(who, input.Text())
+	}
+	leaving <- ch
+	repartir(who, who+" se ha desconectado")
+	conn.Close()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	journalPath = flag.String("journal", "", "fichero donde anotar cada mensaje recibido (remitente, hora y número); vacío = sin journal")
+	replayPath  = flag.String("replay", "", "journal a reenviar a los talleres conectados al arrancar")
+	velocidad   = flag.Float64("velocidad", 1, "velocidad del replay: 1 = la original, 10 = diez veces más rápido, 0 = sin esperas")
+	esperaRep   = flag.Duration("espera", 5*time.Second, "tiempo que se deja a los talleres para conectarse antes del replay de -replay")
+)
+
+// Entrada es un mensaje del journal, una línea JSON por mensaje. De es la
+// dirección de la conexión que lo mandó, o "servidor" para los ok del
+// confirmador.
+type Entrada struct {
+	Seq  uint64    `json:"seq"`
+	Hora time.Time `json:"hora"`
+	De   string    `json:"de,omitempty"`
+	Msg  string    `json:"msg"`
+}
+
+// aviso es un mensaje camino del broadcaster: quién lo manda y si viene de
+// un replay, que ya está en algún journal y no se vuelve a anotar.
+type aviso struct {
+	de, msg string
+	replay  bool
+}
+
+// Journal anota en disco, sin borrar nunca, los mensajes antes de
+// repartirlos. Todo lo que va al broadcaster pasa por él, así que el
+// journal tiene el mismo orden que el reparto. Como el broadcaster, una
+// goroutine es la dueña del fichero.
+type Journal struct {
+	ch   chan aviso
+	done chan struct{}
+	err  error // lo que rompió el journal; solo lo toca loop
+}
+
+// journal es el de la ejecución (nil = sin journal).
+var journal *Journal
+
+// abrirJournal abre (o crea) el journal en path, sigue su numeración y
+// pasa a reparto lo que anota. Una última línea a medias (caída a mitad de
+// escritura) se quita, para no pegarle detrás la siguiente.
+func abrirJournal(path string, reparto chan<- string) (*Journal, error) {
+	var seq uint64
+	if es, err := leerJournal(path); err == nil && len(es) > 0 {
+		seq = es[len(es)-1].Seq
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := io.ReadAll(f); err != nil {
+		f.Close()
+		return nil, err
+	} else if n := len(data); n > 0 && data[n-1] != '\n' {
+		if err := f.Truncate(int64(bytes.LastIndexByte(data, '\n') + 1)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	j := &Journal{ch: make(chan aviso, 256), done: make(chan struct{})}
+	go j.loop(f, seq, reparto)
+	return j, nil
+}
+
+// Cerrar escribe lo pendiente y cierra el fichero. No se puede repartir
+// por el journal después. Devuelve el error que lo rompió, si lo hubo.
+func (j *Journal) Cerrar() error {
+	close(j.ch)
+	<-j.done
+	return j.err
+}
+
+// repartir manda msg a todos los clientes de parte de de: con journal, a
+// través de él, que lo anota antes.
+func repartir(de, msg string) {
+	if journal != nil {
+		journal.ch <- aviso{de: de, msg: msg}
+		return
+	}
+	messages <- msg
+}
+
+// reenviar es repartir para un replay: msg no se anota.
+func reenviar(msg string) {
+	if journal != nil {
+		journal.ch <- aviso{msg: msg, replay: true}
+		return
+	}
+	messages <- msg
+}
+
+func (j *Journal) loop(f *os.File, seq uint64, reparto chan<- string) {
+	defer close(j.done)
+	defer f.Close()
+	out := bufio.NewWriter(f)
+	sucio := false // hay algo escrito sin vaciar
+	romper := func(err error) {
+		j.err = fmt.Errorf("journal: %v", err)
+		log.Printf("%v; no se anota nada más", j.err)
+	}
+	vaciar := func() {
+		err := out.Flush()
+		if err == nil {
+			err = f.Sync()
+		}
+		if err != nil {
+			romper(err)
+		}
+		sucio = false
+	}
+
+	for a := range j.ch {
+		// Roto, se sigue repartiendo: el journal no para el servidor.
+		if !a.replay && j.err == nil {
+			seq++
+			data, _ := json.Marshal(Entrada{Seq: seq, Hora: time.Now(), De: a.de, Msg: a.msg})
+			if _, err := out.Write(append(data, '\n')); err != nil {
+				romper(err)
+			}
+			sucio = true
+		}
+		// Se vacía en cuanto no queda nada pendiente.
+		if sucio && j.err == nil && len(j.ch) == 0 {
+			vaciar()
+		}
+		reparto <- a.msg
+	}
+	if sucio && j.err == nil {
+		vaciar()
+	}
+}
+
+// leerJournal lee un journal entero. Una última línea a medias se ignora.
+func leerJournal(path string) ([]Entrada, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var es []Entrada
+	var mala error // línea que no se entiende: solo vale si es la última
+	input := bufio.NewScanner(f)
+	for n := 1; input.Scan(); n++ {
+		if mala != nil {
+			return nil, mala
+		}
+		var e Entrada
+		if err := json.Unmarshal(input.Bytes(), &e); err != nil {
+			mala = fmt.Errorf("journal %s: línea %d: %v", path, n, err)
+			continue
+		}
+		es = append(es, e)
+	}
+	return es, input.Err()
+}
+
+// reproducir reenvía los mensajes del journal a los talleres conectados,
+// respetando el tiempo entre ellos dividido por vel (0 = sin esperas).
+func reproducir(path string, vel float64) error {
+	es, err := leerJournal(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("replay de %s: %d mensajes a velocidad %v", path, len(es), vel)
+	for i, e := range es {
+		if i > 0 && vel > 0 {
+			time.Sleep(time.Duration(float64(e.Hora.Sub(es[i-1].Hora)) / vel))
+		}
+		msg, ok := contenido(e.Msg)
+		if !ok {
+			continue
+		}
+		reenviar(msg)
+	}
+	log.Printf("replay de %s terminado", path)
+	return nil
+}
+
+func consola() {
+	input := bufio.NewScanner(os.Stdin)
+	for input.Scan() {
+		campos := strings.Fields(input.Text())
+		if len(campos) == 0 {
+			continue
+		}
+		if campos[0] != "replay" || len(campos) < 2 || len(campos) > 3 {
+			log.Print("orden desconocida; uso: replay fichero [velocidad]")
+			continue
+		}
+		vel := 1.0
+		if len(campos) == 3 {
+			v, err := strconv.ParseFloat(campos[2], 64)
+			if err != nil || v < 0 {
+				log.Printf("velocidad no válida: %q", campos[2])
+				continue
+			}
+			vel = v
+		}
+		go func(path string) {
+			if err := reproducir(path, vel); err != nil {
+				log.Print(err)
+			}
+		}(campos[1])
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// anotar manda los mensajes de de por el journal, lo cierra y devuelve lo
+// que repartió.
+func anotar(t *testing.T, path, de string, msgs ...string) []string {
+	t.Helper()
+	reparto := make(chan string, len(msgs))
+	j, err := abrirJournal(path, reparto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range msgs {
+		j.ch <- aviso{de: de, msg: m}
+	}
+	if err := j.Cerrar(); err != nil {
+		t.Fatal(err)
+	}
+	close(reparto)
+	var r []string
+	for m := range reparto {
+		r = append(r, m)
+	}
+	return r
+}
+
+// Al volver a abrir un journal, la numeración sigue donde estaba.
+func TestJournal_SigueNumeracion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sesion.jsonl")
+	if r := anotar(t, path, "127.0.0.1:5000", "0", "3", "5"); !reflect.DeepEqual(r, []string{"0", "3", "5"}) {
+		t.Fatalf("repartido %q", r)
+	}
+	anotar(t, path, "127.0.0.1:5001", "6", "0")
+
+	es, err := leerJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 5 {
+		t.Fatalf("%d entradas, quería 5", len(es))
+	}
+	for i, e := range es {
+		if e.Seq != uint64(i+1) {
+			t.Errorf("entrada %d: seq %d", i, e.Seq)
+		}
+	}
+	if es[2].De != "127.0.0.1:5000" || es[3].De != "127.0.0.1:5001" || es[3].Msg != "6" {
+		t.Errorf("entradas %+v", es)
+	}
+}
+
+// Una última línea a medias (caída escribiendo) se ignora al leer y se
+// quita al volver a abrir; una mala en medio es un error.
+func TestJournal_LineaAMedias(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sesion.jsonl")
+	anotar(t, path, "127.0.0.1:5000", "3", "5")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq": 3, "ho`)
+	f.Close()
+
+	if es, err := leerJournal(path); err != nil || len(es) != 2 {
+		t.Fatalf("%d entradas, %v; quería 2 sin error", len(es), err)
+	}
+	anotar(t, path, "127.0.0.1:5000", "6")
+	es, err := leerJournal(path)
+	if err != nil || len(es) != 3 || es[2].Seq != 3 || es[2].Msg != "6" {
+		t.Fatalf("entradas %+v, %v; quería el 6 como tercera", es, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append([]byte("no es json\n"), data...), 0o644)
+	if _, err := leerJournal(path); err == nil {
+		t.Fatal("una línea mala en medio debería dar error")
+	}
+}
+
+// El replay reparte solo los códigos y no los anota; un mensaje en vivo
+// igual a uno del replay sí se anota.
+func TestReproducir_NoSeAnota(t *testing.T) {
+	dir := t.TempDir()
+	grabado := filepath.Join(dir, "grabado.jsonl")
+	anotar(t, grabado, "127.0.0.1:5000",
+		"127.0.0.1:5000 Se ha conectado",
+		`{"id": 1, "codigo": 3, "emisor": "mutua-norte"}`,
+		"ok mutua-norte 1",
+		`{"ack": 1, "codigo": 3, "origen": "mutua-norte", "taller": "127.0.0.1:5001", "resultado": "aplicado"}`,
+		"5",
+	)
+
+	reparto := make(chan string, 16)
+	path := filepath.Join(dir, "sesion.jsonl")
+	j, err := abrirJournal(path, reparto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal = j
+	defer func() { journal = nil }()
+
+	if err := reproducir(grabado, 0); err != nil {
+		t.Fatal(err)
+	}
+	repartir("127.0.0.1:5002", "5")
+	if err := j.Cerrar(); err != nil {
+		t.Fatal(err)
+	}
+	close(reparto)
+	var r []string
+	for m := range reparto {
+		r = append(r, m)
+	}
+	if !reflect.DeepEqual(r, []string{"3", "5", "5"}) {
+		t.Errorf("repartido %q, quería los dos códigos del replay y el 5 en vivo", r)
+	}
+	es, err := leerJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 1 || es[0].Msg != "5" || es[0].De != "127.0.0.1:5002" {
+		t.Errorf("anotado %+v, quería solo el 5 en vivo", es)
+	}
+}
+
+// Si no se puede escribir, el journal se rompe: no para el reparto y
+// Cerrar devuelve el error.
+func TestJournal_Roto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sesion.jsonl")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path) // solo lectura: falla al escribir
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 5000
+	reparto := make(chan string, n)
+	j := &Journal{ch: make(chan aviso, 256), done: make(chan struct{})}
+	go j.loop(f, 0, reparto)
+
+	for i := 0; i < n; i++ {
+		j.ch <- aviso{de: "127.0.0.1:5000", msg: "3"}
+	}
+	if err := j.Cerrar(); err == nil {
+		t.Fatal("Cerrar sin error con el journal roto")
+	}
+	if len(reparto) != n {
+		t.Fatalf("repartidos %d de %d", len(reparto), n)
+	}
+}
+
+// arrancarBroadcaster lanza el broadcaster una vez para todos los tests
+// (go test -count).
+var arrancarBroadcaster sync.Once
+
+// Con journal, los códigos sueltos (los de la mutua sin flags) se anotan
+// con la dirección de la conexión que los manda.
+func TestAtender_AnotaRemitente(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sesion.jsonl")
+	j, err := abrirJournal(path, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal = j
+	defer func() { journal = nil }()
+	arrancarBroadcaster.Do(func() { go broadcaster() })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			atender(conn)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	who := conn.LocalAddr().String()
+	input := bufio.NewScanner(conn)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if !input.Scan() || input.Text() != "Taller localizado en "+who {
+		t.Fatalf("recibido %q, quería el saludo", input.Text())
+	}
+	conn.Write([]byte("3\n"))
+	for input.Scan() && input.Text() != "3" {
+		// su propio aviso de conexión, según cuándo lo saque el journal
+	}
+	if input.Text() != "3" {
+		t.Fatalf("recibido %q, quería el 3 repartido", input.Text())
+	}
+	conn.Close()
+	// Lo último que se anota es el aviso de la desconexión.
+	for plazo := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		es, _ := leerJournal(path)
+		if len(es) == 3 {
+			if es[1].Msg != "3" || es[1].De != who || es[2].Msg != who+" se ha desconectado" {
+				t.Fatalf("anotado %+v", es)
+			}
+			return
+		}
+		if time.Now().After(plazo) {
+			t.Fatalf("anotado %+v, quería 3 entradas", es)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+// esAviso dice si la línea es uno de los avisos de conexión de handleConn
+// o atender.
+func esAviso(linea string) bool {
+	return strings.HasSuffix(linea, " Se ha conectado") || strings.HasSuffix(linea, " se ha desconectado")
+}
+
+// contenido es lo que se reparte a los talleres en un replay: solo los
+// códigos, porque ya no hay nadie esperando confirmaciones. Las
+// confirmaciones, los Ack y los avisos de conexión se quedan en el journal
+// pero no se reenvían (false).
+func contenido(linea string) (string, bool) {
+	if s, ok := sobres.Abrir(linea); ok {
+		return strconv.Itoa(s.Codigo), true
+	}
+	if _, ok := sobres.AbrirAck(linea); ok || esAviso(linea) {
+		return "", false
+	}
+	if _, _, ok := sobres.AbrirOK(linea); ok {
+		return "", false
+	}
+	return linea, true
+}
+
+// confirmador es un cliente del broadcaster que confirma los sobres. El
+// broadcaster reparte un mensaje a todos los clientes antes de coger el
+// siguiente, así que el "ok" que manda al ver un sobre se reparte cuando
+// el sobre ya ha llegado a todos. No puede bloquearse mandando a messages
+// mientras el broadcaster le manda a él: los ok esperan en una cola y solo
+// se ofrecen a messages (o al journal, si lo hay) cuando hay alguno.
+func confirmador() {
+	ch := make(chan string)
+	entering <- ch
+
+	var cola []string
+	for {
+		var out chan<- string
+		var alJournal chan<- aviso
+		var siguiente string
+		if len(cola) > 0 {
+			siguiente = cola[0]
+			if journal != nil {
+				alJournal = journal.ch
+			} else {
+				out = messages
+			}
+		}
+
+		select {
+		case linea := <-ch:
+			s, ok := sobres.Abrir(linea)
+			if !ok || s.Emisor == "" {
+				continue // sin emisor no hay a quién confirmarlo
+			}
+			if s.Tema != "" || s.Motivo != "" {
+				log.Printf("%s: %d (tema %q, motivo %q) entregado", s.Emisor, s.Codigo, s.Tema, s.Motivo)
+			}
+			cola = append(cola, sobres.OK(s.Emisor, s.ID))
+		case out <- siguiente:
+			cola = cola[1:]
+		case alJournal <- aviso{de: "servidor", msg: siguiente}:
+			cola = cola[1:]
+		}
+	}
+}
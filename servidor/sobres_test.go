@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+// En un replay solo se reenvían los códigos: el de un sobre, sin sobre, y
+// los que llegaron sueltos. Lo demás que anota el journal se salta.
+func TestContenido(t *testing.T) {
+	for _, tc := range []struct {
+		linea string
+		msg   string
+		ok    bool
+	}{
+		{"3", "3", true},
+		{`{"id": 7, "codigo": 4, "emisor": "mutua-norte"}`, "4", true},
+		{`{"ack": 7, "codigo": 4, "origen": "mutua-norte", "taller": "127.0.0.1:5000", "resultado": "aplicado"}`, "", false},
+		{"ok mutua-norte 7", "", false},
+		{"127.0.0.1:5000 Se ha conectado", "", false},
+		{"127.0.0.1:5000 se ha desconectado", "", false},
+	} {
+		msg, ok := contenido(tc.linea)
+		if msg != tc.msg || ok != tc.ok {
+			t.Errorf("%s: %q %v, quería %q %v", tc.linea, msg, ok, tc.msg, tc.ok)
+		}
+	}
+}
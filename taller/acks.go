@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+// Codigo es un código de estado camino del controlador. Si llegó en un
+// sobre, el controlador comprueba su secuencia y lo confirma. Fuente sirve
+// para los que no llegan en sobre (vacía = la mutua de siempre).
+type Codigo struct {
+	N      int
+	Sobre  *sobres.Sobre
+	Fuente string
+}
+
+// ackCh lleva los Ack a la goroutine que los escribe en la conexión con el
+// servidor.
+var ackCh = make(chan sobres.Ack, 64)
+
+// confirmarSobre manda el Ack de un sobre. Nunca bloquea al controlador; si
+// la conexión está atascada el Ack se pierde (la mutua lo verá como no
+// confirmado y lo reenviará, y entonces será un duplicado).
+func confirmarSobre(s sobres.Sobre, st TallerState, resultado string) {
+	a := sobres.Ack{Ack: s.ID, Codigo: s.Codigo, Origen: s.Emisor, Aplicado: time.Now(),
+		Estado: stateSummary(st), Resultado: resultado}
+	select {
+	case ackCh <- a:
+	default:
+		debugln("ACK PERDIDO:", a.Ack, "de", a.Origen)
+	}
+}
+
+// maxHueco limita los números que se recuerdan de un hueco.
+const maxHueco = 1000
+
+// secuencia es lo último visto de un emisor.
+type secuencia struct {
+	sesion int64
+	ultimo uint64
+	faltan map[uint64]bool // números saltados que aún pueden llegar tarde
+}
+
+// Secuencias lleva la secuencia de cada emisor de sobres. Es del controlador.
+type Secuencias map[string]*secuencia
+
+// Comprobar dice qué hacer con un sobre: aplicarlo, o no porque es un
+// duplicado o llega tarde. Anota los huecos en la numeración.
+func (ss Secuencias) Comprobar(s sobres.Sobre) string {
+	emisor := s.Emisor
+	sec, ok := ss[emisor]
+	switch {
+	case !ok || s.Sesion > sec.sesion:
+		// Emisor nuevo o que ha vuelto a arrancar: empieza de cero.
+		ss[emisor] = &secuencia{sesion: s.Sesion, ultimo: s.ID, faltan: map[uint64]bool{}}
+		return sobres.ResultadoAplicado
+	case s.Sesion < sec.sesion:
+		return sobres.ResultadoObsoleto
+	case s.ID == sec.ultimo:
+		return sobres.ResultadoDuplicado
+	case s.ID < sec.ultimo:
+		if sec.faltan[s.ID] {
+			delete(sec.faltan, s.ID)
+			debugln(fmt.Sprintf("SOBRE %d de %s fuera de orden (ya va por el %d): se descarta", s.ID, emisor, sec.ultimo))
+			return sobres.ResultadoObsoleto
+		}
+		return sobres.ResultadoDuplicado
+	}
+
+	if s.ID > sec.ultimo+1 {
+		debugln(fmt.Sprintf("HUECO en los sobres de %s: faltan del %d al %d", emisor, sec.ultimo+1, s.ID-1))
+		desde := sec.ultimo + 1
+		if s.ID-desde > maxHueco {
+			desde = s.ID - maxHueco
+		}
+		for id := desde; id < s.ID; id++ {
+			sec.faltan[id] = true
+		}
+	}
+	sec.ultimo = s.ID
+	return sobres.ResultadoAplicado
+}
+
+// enviarAcks escribe en la conexión con el servidor los Ack de los sobres
+// aplicados, firmados con la dirección del taller (la que el servidor ve).
+// Bloquea (lanzar como goroutine).
+func enviarAcks(conn net.Conn) {
+	for a := range ackCh {
+		a.Taller = conn.LocalAddr().String()
+		data, _ := json.Marshal(a)
+		if _, err := fmt.Fprintf(conn, "%s\n", data); err != nil {
+			debugln("ACK:", err)
+		}
+	}
+}
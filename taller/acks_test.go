@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+// Un emisor manda 1, 2, 4 (se pierde el 3), repite el 4, el 3 llega tarde
+// y luego vuelve a arrancar (sesión nueva) empezando por el 1.
+func TestSecuencias_DuplicadosYHuecos(t *testing.T) {
+	ss := Secuencias{}
+	sobre := func(sesion int64, id uint64) sobres.Sobre {
+		return sobres.Sobre{ID: id, Emisor: "mutua", Sesion: sesion}
+	}
+
+	for _, tc := range []struct {
+		sobre sobres.Sobre
+		want  string
+	}{
+		{sobre(1, 1), sobres.ResultadoAplicado},
+		{sobre(1, 2), sobres.ResultadoAplicado},
+		{sobre(1, 4), sobres.ResultadoAplicado}, // hueco: falta el 3
+		{sobre(1, 4), sobres.ResultadoDuplicado},
+		{sobre(1, 3), sobres.ResultadoObsoleto}, // llega tarde: ya se aplicó el 4
+		{sobre(1, 3), sobres.ResultadoDuplicado},
+		{sobre(1, 1), sobres.ResultadoDuplicado},
+		{sobre(2, 1), sobres.ResultadoAplicado},                              // la mutua ha vuelto a arrancar
+		{sobre(1, 5), sobres.ResultadoObsoleto},                              // de la sesión anterior
+		{sobres.Sobre{ID: 1, Emisor: "mutua-sur"}, sobres.ResultadoAplicado}, // otro emisor
+	} {
+		if got := ss.Comprobar(tc.sobre); got != tc.want {
+			t.Fatalf("sobre %+v: %s, quería %s", tc.sobre, got, tc.want)
+		}
+	}
+}
+
+// Solo los sobres llevan secuencia: un sobre repetido no se aplica dos
+// veces, pero un código suelto repetido sí (no hay con qué reconocerlo).
+func TestController_SoloSobresConSecuencia(t *testing.T) {
+	codes := make(chan Codigo)
+	queries := make(chan stateRequest)
+	f, _ := NuevaFusion(FusionUltimo, nil, defaultState())
+	go controller(f, codes, queries)
+	defer close(codes)
+	estado := func() string {
+		reply := make(chan TallerState)
+		queries <- stateRequest{reply: reply}
+		return stateSummary(<-reply)
+	}
+
+	sobre := &sobres.Sobre{ID: 1, Codigo: CodigoMecanicoSeVa, Emisor: "norte", Sesion: 1}
+	codes <- Codigo{N: CodigoMecanicoSeVa, Sobre: sobre}
+	codes <- Codigo{N: CodigoMecanicoSeVa, Sobre: sobre} // reenvío
+	if got := estado(); got != "NORMAL (-1 mecánicos)" {
+		t.Fatalf("sobre repetido: %q", got)
+	}
+
+	codes <- Codigo{N: CodigoMecanicoSeVa}
+	codes <- Codigo{N: CodigoMecanicoSeVa} // el mismo, otra vez
+	if got := estado(); got != "NORMAL (-3 mecánicos)" {
+		t.Fatalf("código suelto repetido: %q", got)
+	}
+
+	// Uno suelto atrasado tampoco se reconoce: deshace el estado más reciente.
+	codes <- Codigo{N: 9}
+	codes <- Codigo{N: 4}
+	if got := estado(); got != "PRIORIDAD A (-3 mecánicos) [mutua]" {
+		t.Fatalf("código suelto atrasado: %q", got)
+	}
+
+	for len(ackCh) > 0 {
+		<-ackCh // los Ack de los sobres, que aquí no lee nadie
+	}
+}
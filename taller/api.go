@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// API HTTP de control del taller. Permite inspeccionar y manejar la
+// simulación sin pasar por el broadcast TCP del servidor:
+//
+//	GET   /estado    estado actual (TallerState + resumen)
+//	POST  /estado    {"codigo": 0..11}  aplica un código como si viniera de una mutua (la fuente "api")
+//	GET   /colas     contenido de las colas de las fases 1..3
+//	POST  /coches    {"categoria": "A"|"B"|"C", "urgente": false}  mete un coche nuevo en fase 0
+//	GET   /recursos  capacidad/ocupación de cada recurso
+//	PATCH /recursos  {"mecanicos": 3, ...}  cambia la capacidad de los recursos
+//	                 (?esperar=true no responde hasta que los ocupados caben)
+//	GET   /informe   informe de la ejecución hasta ahora (texto)
+//	GET   /reservas  citas aceptadas ("en" en segundos, como al pedirlas)
+//	POST  /reservas  {"categoria": "A", "en": 30}  cita a los 30 s (409 si no cabe)
+//	GET   /inventario  stock y lotes en camino de cada pieza
+type controlAPI struct {
+	sim      *Simulation
+	codes    chan<- Codigo
+	informes chan<- chan string
+}
+
+type estadoResp struct {
+	Estado  TallerState `json:"estado"`
+	Resumen string      `json:"resumen"`
+}
+
+type codigoReq struct {
+	Codigo *int `json:"codigo"`
+}
+
+type cocheReq struct {
+	Categoria string `json:"categoria"`
+	Urgente   bool   `json:"urgente"`
+}
+
+// reservaBody es una cita tal y como entra (categoría y en) y sale por la
+// API: En va en segundos en los dos sentidos.
+type reservaBody struct {
+	ID         int     `json:"id,omitempty"`
+	Categoria  string  `json:"categoria"`
+	En         float64 `json:"en"` // segundos de simulación desde el arranque
+	Sobreventa bool    `json:"sobreventa,omitempty"`
+	CocheID    int     `json:"cocheId,omitempty"`
+}
+
+func reservaJSON(r Reserva) reservaBody {
+	return reservaBody{ID: r.ID, Categoria: r.Categoria, En: r.En.Seconds(), Sobreventa: r.Sobreventa, CocheID: r.CocheID}
+}
+
+// serveAPI arranca el servidor HTTP en addr. Bloquea (lanzar como goroutine).
+func serveAPI(addr string, sim *Simulation, codes chan<- Codigo, informes chan<- chan string) {
+	api := &controlAPI{sim: sim, codes: codes, informes: informes}
+	if err := http.ListenAndServe(addr, api.routes()); err != nil {
+		log.Println("api:", err)
+	}
+}
+
+func (api *controlAPI) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /estado", api.getEstado)
+	mux.HandleFunc("POST /estado", api.postEstado)
+	mux.HandleFunc("GET /colas", api.getColas)
+	mux.HandleFunc("POST /coches", api.postCoche)
+	mux.HandleFunc("GET /recursos", api.getRecursos)
+	mux.HandleFunc("PATCH /recursos", api.patchRecursos)
+	mux.HandleFunc("GET /informe", api.getInforme)
+	mux.HandleFunc("GET /reservas", api.getReservas)
+	mux.HandleFunc("POST /reservas", api.postReserva)
+	mux.HandleFunc("GET /inventario", api.getInventario)
+	return mux
+}
+
+func (api *controlAPI) getEstado(w http.ResponseWriter, r *http.Request) {
+	st := getState()
+	writeJSON(w, http.StatusOK, estadoResp{Estado: st, Resumen: stateSummary(st)})
+}
+
+func (api *controlAPI) postEstado(w http.ResponseWriter, r *http.Request) {
+	var req codigoReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Codigo == nil || *req.Codigo < 0 || *req.Codigo > maxCodigo {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("codigo debe estar entre 0 y %d", maxCodigo))
+		return
+	}
+
+	// Mismo camino que los códigos que llegan por TCP.
+	api.codes <- Codigo{N: *req.Codigo, Fuente: FuenteAPI}
+	writeJSON(w, http.StatusAccepted, req)
+}
+
+func (api *controlAPI) getColas(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, api.sim.Colas())
+}
+
+func (api *controlAPI) postCoche(w http.ResponseWriter, r *http.Request) {
+	var req cocheReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch req.Categoria {
+	case CatA, CatB, CatC:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("categoria desconocida: %q", req.Categoria))
+		return
+	}
+	if req.Urgente {
+		writeJSON(w, http.StatusCreated, api.sim.AddCocheUrgente(req.Categoria))
+		return
+	}
+	writeJSON(w, http.StatusCreated, api.sim.AddCoche(req.Categoria))
+}
+
+func (api *controlAPI) getRecursos(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, api.sim.Recursos())
+}
+
+func (api *controlAPI) patchRecursos(w http.ResponseWriter, r *http.Request) {
+	var req map[string]int
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// Validamos todo antes de aplicar nada para no dejar cambios a medias.
+	for recurso, n := range req {
+		if api.sim.pool(recurso) == nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("valor no válido para %q: %d", recurso, n))
+			return
+		}
+	}
+	for recurso, n := range req {
+		if err := api.sim.Resize(recurso, n); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	// Al encoger, opcionalmente esperamos a que terminen los trabajos sobrantes.
+	if r.URL.Query().Get("esperar") == "true" {
+		for recurso := range req {
+			api.sim.pool(recurso).WaitFits()
+		}
+	}
+	writeJSON(w, http.StatusOK, api.sim.Recursos())
+}
+
+func (api *controlAPI) getInforme(w http.ResponseWriter, r *http.Request) {
+	reply := make(chan string, 1)
+	api.informes <- reply
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, <-reply)
+}
+
+func (api *controlAPI) getReservas(w http.ResponseWriter, r *http.Request) {
+	out := []reservaBody{}
+	for _, res := range api.sim.Reservas() {
+		out = append(out, reservaJSON(res))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (api *controlAPI) getInventario(w http.ResponseWriter, r *http.Request) {
+	if api.sim.inventario == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("el taller no lleva inventario de piezas"))
+		return
+	}
+	writeJSON(w, http.StatusOK, api.sim.inventario.Snapshot())
+}
+
+func (api *controlAPI) postReserva(w http.ResponseWriter, r *http.Request) {
+	var req reservaBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch req.Categoria {
+	case CatA, CatB, CatC:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("categoria desconocida: %q", req.Categoria))
+		return
+	}
+	res, err := api.sim.Reservar(req.Categoria, time.Duration(req.En*float64(time.Second)))
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, reservaJSON(res))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
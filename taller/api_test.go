@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// POST /estado pasa el código al controlador (como la fuente "api") y
+// GET /estado devuelve el estado resultante. Los códigos fuera de rango y
+// los cuerpos mal formados se rechazan sin llegar al controlador.
+func TestAPI_Estado(t *testing.T) {
+	codes := make(chan Codigo)
+	queries := make(chan stateRequest)
+	f, _ := NuevaFusion(FusionUltimo, nil, defaultState())
+	go controller(f, codes, queries)
+	anterior := stateQueryCh
+	stateQueryCh = queries
+	t.Cleanup(func() {
+		stateQueryCh = anterior
+		close(codes)
+	})
+
+	// El controlador es el que lee de codes: se intercala para ver qué llega.
+	aControlador := make(chan Codigo, 1)
+	api := &controlAPI{codes: aControlador}
+	srv := httptest.NewServer(api.routes())
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		cuerpo string
+		status int
+	}{
+		{`{"codigo": 12}`, http.StatusBadRequest},
+		{`{"codigo": -1}`, http.StatusBadRequest},
+		{`{}`, http.StatusBadRequest},
+		{`codigo=3`, http.StatusBadRequest},
+		{`{"codigo": 2}`, http.StatusAccepted},
+	} {
+		resp, err := http.Post(srv.URL+"/estado", "application/json", strings.NewReader(tc.cuerpo))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("POST %s: %d, quería %d", tc.cuerpo, resp.StatusCode, tc.status)
+		}
+	}
+
+	c := <-aControlador
+	if c.N != 2 || c.fuente() != FuenteAPI {
+		t.Fatalf("llega %+v (fuente %q), quería el 2 de la api", c, c.fuente())
+	}
+	codes <- c
+
+	resp, err := http.Get(srv.URL + "/estado")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got estadoResp
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Resumen != "SOLO B [api]" || got.Estado.SoloCategoria != CatB {
+		t.Fatalf("GET /estado: %+v", got)
+	}
+}
+
+// Las citas se piden y se devuelven con "en" en segundos.
+func TestAPI_Reservas(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+	cfg := DefaultConfig()
+	cfg.NumA, cfg.NumB, cfg.NumC = 0, 0, 0
+	sim := arrancar(t, time.Now(), make(chan LogEvent, 64), cfg)
+
+	srv := httptest.NewServer((&controlAPI{sim: sim}).routes())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/reservas", "application/json", strings.NewReader(`{"categoria": "B", "en": 3600.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var creada reservaBody
+	err = json.NewDecoder(resp.Body).Decode(&creada)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusCreated || creada.En != 3600.5 || creada.ID == 0 {
+		t.Fatalf("POST /reservas: %d %+v %v", resp.StatusCode, creada, err)
+	}
+
+	resp, err = http.Get(srv.URL + "/reservas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var todas []reservaBody
+	if err := json.NewDecoder(resp.Body).Decode(&todas); err != nil {
+		t.Fatal(err)
+	}
+	if len(todas) != 1 || todas[0] != creada {
+		t.Fatalf("GET /reservas: %+v, quería [%+v]", todas, creada)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// AutoscaleConfig configura el autoescalado de workers de las fases 1..3.
+// Los tiempos son de simulación (sleepFn/simSince).
+type AutoscaleConfig struct {
+	Activo bool
+
+	Intervalo time.Duration // cada cuánto se revisan las colas
+	Cooldown  time.Duration // tiempo mínimo entre dos decisiones del mismo recurso
+
+	// Límites de workers por recurso (RecursoMecanicos, RecursoLimpieza, RecursoEntrega).
+	// Si falta un recurso en Max, ese recurso no se autoescala; si falta en
+	// Min, su mínimo es 1 (con 0 podría quedarse la fase sin nadie).
+	Min map[string]int
+	Max map[string]int
+
+	// Se añade un worker si hay más de CochesPorWorker coches en cola por worker
+	// o si el coche más antiguo lleva más de EsperaMax en cola.
+	CochesPorWorker int
+	EsperaMax       time.Duration
+}
+
+// DefaultAutoscale es una configuración razonable para la ejecución manual.
+func DefaultAutoscale() AutoscaleConfig {
+	return AutoscaleConfig{
+		Activo:    true,
+		Intervalo: 1 * time.Second,
+		Cooldown:  5 * time.Second,
+		Min: map[string]int{
+			RecursoMecanicos: 1, RecursoLimpieza: 1, RecursoEntrega: 1,
+		},
+		Max: map[string]int{
+			RecursoMecanicos: 6, RecursoLimpieza: 3, RecursoEntrega: 3,
+		},
+		CochesPorWorker: 2,
+		EsperaMax:       10 * time.Second,
+	}
+}
+
+// minimo es el límite inferior de workers del recurso.
+func (ac AutoscaleConfig) minimo(recurso string) int {
+	if n, ok := ac.Min[recurso]; ok {
+		return n
+	}
+	return 1
+}
+
+// autoscale vigila la cola de cada fase y añade o quita workers entre Min y Max.
+// Sube si la cola crece o envejece; baja si la cola está vacía y sobra gente.
+// Cada decisión se registra como evento EstadoEscalado.
+func (s *Simulation) autoscale(cfg Config) {
+	ac := cfg.Autoescalado
+
+	type fase struct {
+		recurso string
+		fase    int
+		q       *PhaseQueue
+	}
+	fases := []fase{
+		{RecursoMecanicos, FaseMecanico, s.q1},
+		{RecursoLimpieza, FaseLimpieza, s.q2},
+		{RecursoEntrega, FaseEntrega, s.q3},
+	}
+
+	ultima := map[string]time.Time{}
+
+	for {
+		s.vida.dormir(ac.Intervalo)
+
+		for _, f := range fases {
+			max, ok := ac.Max[f.recurso]
+			if !ok {
+				continue
+			}
+			if t, ok := ultima[f.recurso]; ok && simSince(t) < ac.Cooldown {
+				continue
+			}
+
+			// Se parte de la capacidad pedida ahora (la puede haber cambiado
+			// la API), no de la última que se puso aquí. La cola se compara
+			// con los workers que hay de verdad (sin los mecánicos fuera).
+			q := f.q.Snapshot()
+			p := s.pool(f.recurso).Snapshot()
+			antes := s.fotoLoop().capacidades[f.recurso]
+			n := antes
+
+			var motivo string
+			switch {
+			case n < max && (q.Longitud > ac.CochesPorWorker*p.Capacidad || q.EsperaMax > ac.EsperaMax):
+				n++
+				motivo = "sube"
+			case n > ac.minimo(f.recurso) && q.Longitud == 0 && p.Ocupados < p.Capacidad:
+				n--
+				motivo = "baja"
+			default:
+				continue
+			}
+
+			if err := s.Resize(f.recurso, n); err != nil {
+				continue
+			}
+			s.logs <- LogEvent{
+				Elapsed: simSince(s.start),
+				Fase:    f.fase,
+				Estado:  EstadoEscalado,
+				Detalle: fmt.Sprintf("%s %s %d->%d (cola=%d espera=%v ocupados=%d)",
+					motivo, f.recurso, antes, n, q.Longitud, q.EsperaMax.Truncate(time.Millisecond), p.Ocupados),
+			}
+			ultima[f.recurso] = time.Now()
+		}
+	}
+}
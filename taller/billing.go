@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// CosteFase es lo que cuesta que un coche de una categoría pase por una fase.
+// Los importes son por segundo de simulación (el taller va a escala).
+type CosteFase struct {
+	PorSegundo float64 // mano de obra mientras se trabaja en el coche
+	Fijo       float64 // material, papeleo... una vez por paso por la fase
+}
+
+// CostesConfig es el modelo económico del taller. Cada coche acumula el
+// coste de las fases por las que pasa y al entregarlo se le cobra ese coste
+// más el Margen. Los recursos parados también cuestan (Ocioso).
+type CostesConfig struct {
+	Fases  map[int]map[string]CosteFase // fase -> categoría -> coste
+	Ocioso map[int]float64              // fase -> coste por segundo de cada unidad de su recurso sin usar
+	Margen float64                      // 0.3 = se cobra un 30% sobre el coste
+
+	Muestreo time.Duration // cada cuánto se mide lo que está parado (0 = 10s)
+}
+
+// DefaultCostes son unas tarifas de ejemplo para la ejecución manual.
+func DefaultCostes() CostesConfig {
+	return CostesConfig{
+		Fases: map[int]map[string]CosteFase{
+			FaseMecanico: {CatA: {PorSegundo: 4, Fijo: 20}, CatB: {PorSegundo: 5, Fijo: 15}, CatC: {PorSegundo: 3, Fijo: 10}},
+			FaseLimpieza: {CatA: {PorSegundo: 1}, CatB: {PorSegundo: 1}, CatC: {PorSegundo: 1}},
+			FaseEntrega:  {CatA: {Fijo: 2}, CatB: {Fijo: 2}, CatC: {Fijo: 2}},
+		},
+		Ocioso: map[int]float64{FaseEsperaPlaza: 0.05, FaseMecanico: 0.5, FaseLimpieza: 0.2, FaseEntrega: 0.2},
+		Margen: 0.3,
+	}
+}
+
+func (c CostesConfig) activo() bool {
+	return len(c.Fases) > 0 || len(c.Ocioso) > 0
+}
+
+// coste de un paso de d por la fase para un coche de la categoría.
+func (c CostesConfig) coste(fase int, cat string, d time.Duration) float64 {
+	cf := c.Fases[fase][cat]
+	return cf.Fijo + cf.PorSegundo*d.Seconds()
+}
+
+// importe que se cobra por un coche con el coste acumulado dado.
+func (c CostesConfig) importe(coste float64) float64 {
+	return coste * (1 + c.Margen)
+}
+
+// cobrar suma al coche el coste de su paso por la fase y lo devuelve
+// para el evento Sale; en la entrega, también lo que se le factura.
+func (c CostesConfig) cobrar(car *Coche, fase int, d time.Duration) (coste, importe float64) {
+	coste = c.coste(fase, car.Categoria, d)
+	car.Coste += coste
+	if fase == FaseEntrega {
+		importe = c.importe(car.Coste)
+	}
+	return coste, importe
+}
+
+// cobrarParcial suma al coche la mano de obra de un servicio que deja a
+// medias (expropiado, abortado o averiado con abortar) y la devuelve para
+// el evento. El Fijo no: se cobra una vez, en el Sale de la fase.
+func (c CostesConfig) cobrarParcial(car *Coche, fase int, d time.Duration) float64 {
+	coste := c.Fases[fase][car.Categoria].PorSegundo * d.Seconds()
+	car.Coste += coste
+	return coste
+}
+
+// medirOcioso mide periódicamente las unidades de cada recurso que no se
+// usan y registra su coste como evento Ocioso (una por recurso con coste).
+func (s *Simulation) medirOcioso() {
+	c := s.cfg.Costes
+	cada := c.Muestreo
+	if cada <= 0 {
+		cada = 10 * time.Second
+	}
+
+	recursos := []string{RecursoPlazas, RecursoMecanicos, RecursoLimpieza, RecursoEntrega}
+	antes := simSince(s.start)
+	for {
+		s.vida.dormir(cada)
+		ahora := simSince(s.start)
+		dt := ahora - antes
+		antes = ahora
+
+		for fase, recurso := range recursos {
+			p := s.pool(recurso).Snapshot()
+			parados := p.Capacidad - p.Ocupados
+			if parados <= 0 || c.Ocioso[fase] == 0 {
+				continue
+			}
+			coste := float64(parados) * c.Ocioso[fase] * dt.Seconds()
+			s.logs <- LogEvent{Elapsed: ahora, Fase: fase, Estado: EstadoOcioso, Coste: coste,
+				Detalle: fmt.Sprintf("%d %s parados, %.2f", parados, recurso, coste)}
+		}
+	}
+}
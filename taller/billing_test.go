@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func casiIgual(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+// Un A que pasa 2s en la plaza, 5s en el mecánico, 2s en limpieza y 1s en la
+// entrega cuesta 0 + (20+4*5) + 1*2 + 2 = 44 con las tarifas de ejemplo, y se
+// le factura 44 * 1.3 = 57.2 en la entrega (y solo en la entrega).
+func TestCostes_Cobrar(t *testing.T) {
+	c := DefaultCostes()
+	car := Coche{ID: 1, Categoria: CatA}
+
+	for _, paso := range []struct {
+		fase    int
+		d       time.Duration
+		coste   float64
+		importe float64
+	}{
+		{FaseEsperaPlaza, 2 * time.Second, 0, 0},
+		{FaseMecanico, 5 * time.Second, 40, 0},
+		{FaseLimpieza, 2 * time.Second, 2, 0},
+		{FaseEntrega, time.Second, 2, 57.2},
+	} {
+		coste, importe := c.cobrar(&car, paso.fase, paso.d)
+		if !casiIgual(coste, paso.coste) || !casiIgual(importe, paso.importe) {
+			t.Errorf("fase %d (%v): coste %.2f importe %.2f, quería %.2f %.2f", paso.fase, paso.d, coste, importe, paso.coste, paso.importe)
+		}
+	}
+	if !casiIgual(car.Coste, 44) {
+		t.Fatalf("coste acumulado %.2f, quería 44", car.Coste)
+	}
+}
+
+// Sin coches todo está parado: cada muestra de un recurso cuesta sus
+// unidades * la tarifa de Ocioso * lo que ha pasado desde la anterior.
+func TestCostes_Ocioso(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	cfg := DefaultConfig()
+	cfg.NumA, cfg.NumB, cfg.NumC = 0, 0, 0
+	cfg.Costes = DefaultCostes()
+	cfg.Costes.Muestreo = time.Second
+
+	logCh := make(chan LogEvent, 1024)
+	arrancar(t, time.Now(), logCh, cfg)
+
+	unidades := map[int]int{FaseEsperaPlaza: cfg.NumPlazas, FaseMecanico: cfg.NumMecanicos, FaseLimpieza: cfg.NumLimpieza, FaseEntrega: cfg.NumEntrega}
+	anterior := map[int]time.Duration{}
+	muestras := 0
+	timeout := time.After(time.Minute)
+	for muestras < 4*len(unidades) {
+		select {
+		case ev := <-logCh:
+			if ev.Estado != EstadoOcioso {
+				continue
+			}
+			antes, ok := anterior[ev.Fase]
+			anterior[ev.Fase] = ev.Elapsed
+			if !ok {
+				continue // la primera muestra mide desde el arranque
+			}
+			want := float64(unidades[ev.Fase]) * cfg.Costes.Ocioso[ev.Fase] * (ev.Elapsed - antes).Seconds()
+			if !casiIgual(ev.Coste, want) {
+				t.Errorf("fase %d: ocioso %.3f en %v, quería %.3f", ev.Fase, ev.Coste, ev.Elapsed-antes, want)
+			}
+			muestras++
+		case <-timeout:
+			t.Fatalf("timeout con %d muestras", muestras)
+		}
+	}
+}
+
+// Un A expropiado por un urgente en el mecánico paga lo que llevaba hecho
+// (sin el Fijo, que se cobra al acabar) y se le factura todo al entregarlo.
+func TestCostes_Expropiado(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	cfg := DefaultConfig()
+	cfg.NumA, cfg.NumB, cfg.NumC = 1, 0, 0
+	cfg.NumMecanicos = 1
+	cfg.Expropiativo = true
+	cfg.Costes = DefaultCostes()
+
+	logCh := make(chan LogEvent, 1024)
+	s := arrancar(t, time.Now(), logCh, cfg)
+
+	var entra time.Duration
+	var parcial, total float64
+	timeout := time.After(time.Minute)
+	for {
+		select {
+		case ev := <-logCh:
+			if ev.CocheID != 1 {
+				continue
+			}
+			total += ev.Coste
+			switch {
+			case ev.Fase == FaseMecanico && ev.Estado == EstadoEntra && parcial == 0:
+				entra = ev.Elapsed
+				s.AddCocheUrgente(CatC)
+			case ev.Estado == EstadoExpropiado:
+				parcial = ev.Coste
+				want := cfg.Costes.Fases[FaseMecanico][CatA].PorSegundo * (ev.Elapsed - entra).Seconds()
+				if parcial <= 0 || !casiIgual(parcial, want) {
+					t.Fatalf("expropiado tras %v: coste %.2f, quería %.2f", ev.Elapsed-entra, parcial, want)
+				}
+			case ev.Fase == FaseEntrega && ev.Estado == EstadoSale:
+				if parcial == 0 {
+					t.Fatal("el A se entregó sin que lo expropiaran")
+				}
+				if !casiIgual(ev.Importe, cfg.Costes.importe(total)) {
+					t.Fatalf("facturado %.2f, quería %.2f (costes %.2f con %.2f expropiado)", ev.Importe, cfg.Costes.importe(total), total, parcial)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("timeout esperando la entrega del A")
+		}
+	}
+}
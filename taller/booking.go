@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reserva es una cita de un cliente: un coche de una categoría que llegará
+// al taller en el instante En (tiempo de simulación desde el arranque).
+// Por la API viaja como reservaBody (En en segundos).
+type Reserva struct {
+	ID         int
+	Categoria  string
+	En         time.Duration
+	Sobreventa bool // aceptada por encima de la capacidad nominal
+	CocheID    int  // coche creado al llegar la cita
+}
+
+type reservaReq struct {
+	categoria string
+	en        time.Duration
+	reply     chan reservaResp
+}
+
+type reservaResp struct {
+	r   Reserva
+	err error
+}
+
+type citaReq struct {
+	reserva Reserva
+	cocheID int
+}
+
+// Reservar pide una cita. La agenda la acepta si, contando las demás reservas,
+// caben el coche en las plazas durante su estancia esperada y su trabajo en
+// los mecánicos justo después (tiempos base de categoriaBaseDur).
+// Con Config.Sobreventa se aceptan citas por encima de la capacidad nominal.
+func (s *Simulation) Reservar(categoria string, en time.Duration) (Reserva, error) {
+	reply := make(chan reservaResp, 1)
+	enviar(s.vida, s.reservar, reservaReq{categoria: categoria, en: en, reply: reply})
+	resp := recibir(s.vida, reply)
+	return resp.r, resp.err
+}
+
+// Reservas devuelve las reservas aceptadas.
+func (s *Simulation) Reservas() []Reserva {
+	reply := make(chan []Reserva, 1)
+	enviar(s.vida, s.reservasQ, reply)
+	return recibir(s.vida, reply)
+}
+
+// intervalo [desde, hasta) de ocupación esperada de un recurso.
+type intervalo struct {
+	desde, hasta time.Duration
+}
+
+// agendaLoop es la dueña de las reservas: comprueba capacidad, programa la
+// llegada de cada cita y registra aceptaciones, sobreventas y rechazos.
+func (s *Simulation) agendaLoop() {
+	var reservas []Reserva
+	nextID := 1
+	citas := make(chan citaReq)
+
+	// Ocupación esperada de una reserva en plazas y en mecánicos.
+	plaza := func(cat string, en time.Duration) intervalo {
+		return intervalo{en, en + categoriaBaseDur(cat)}
+	}
+	mecanico := func(cat string, en time.Duration) intervalo {
+		return intervalo{en + categoriaBaseDur(cat), en + 2*categoriaBaseDur(cat)}
+	}
+
+	// Máximo de reservas simultáneas (incluida la nueva) dentro de iv.
+	maxSolape := func(iv intervalo, ocupacion func(string, time.Duration) intervalo) int {
+		type punto struct {
+			t     time.Duration
+			delta int
+		}
+		var ps []punto
+		for _, r := range reservas {
+			o := ocupacion(r.Categoria, r.En)
+			if o.desde < iv.hasta && iv.desde < o.hasta {
+				ps = append(ps, punto{max(o.desde, iv.desde), +1}, punto{min(o.hasta, iv.hasta), -1})
+			}
+		}
+		// A igualdad de instante, primero las salidas.
+		sort.Slice(ps, func(i, j int) bool {
+			if ps[i].t != ps[j].t {
+				return ps[i].t < ps[j].t
+			}
+			return ps[i].delta < ps[j].delta
+		})
+		n, best := 0, 0
+		for _, p := range ps {
+			n += p.delta
+			best = max(best, n)
+		}
+		return best + 1
+	}
+
+	limite := func(capacidad int) int {
+		return int(float64(capacidad) * (1 + s.cfg.Sobreventa))
+	}
+
+	rechazar := func(req reservaReq, motivo string) {
+		err := fmt.Errorf("reserva %s a los %v rechazada: %s", req.categoria, req.en, motivo)
+		s.logs <- LogEvent{Elapsed: simSince(s.start), Incidencia: categoriaTipo(req.categoria), Estado: EstadoRechazo, Detalle: err.Error()}
+		req.reply <- reservaResp{err: err}
+	}
+
+	for {
+		select {
+		case <-s.vida.parada():
+			return
+
+		case req := <-s.reservar:
+			if req.en < simSince(s.start) {
+				rechazar(req, "la hora ya ha pasado")
+				continue
+			}
+
+			capPlazas := s.plazas.Snapshot().Capacidad
+			capMecanicos := s.mecanicos.Snapshot().Capacidad
+			nPlazas := maxSolape(plaza(req.categoria, req.en), plaza)
+			nMecanicos := maxSolape(mecanico(req.categoria, req.en), mecanico)
+
+			if nPlazas > limite(capPlazas) {
+				rechazar(req, fmt.Sprintf("plazas completas (%d/%d)", nPlazas-1, capPlazas))
+				continue
+			}
+			if nMecanicos > limite(capMecanicos) {
+				rechazar(req, fmt.Sprintf("mecánicos completos (%d/%d)", nMecanicos-1, capMecanicos))
+				continue
+			}
+
+			r := Reserva{
+				ID:         nextID,
+				Categoria:  req.categoria,
+				En:         req.en,
+				Sobreventa: nPlazas > capPlazas || nMecanicos > capMecanicos,
+			}
+			nextID++
+			reservas = append(reservas, r)
+
+			estado := EstadoReserva
+			if r.Sobreventa {
+				estado = EstadoSobreventa
+			}
+			s.logs <- LogEvent{Elapsed: simSince(s.start), Incidencia: categoriaTipo(r.Categoria), Estado: estado,
+				Detalle: fmt.Sprintf("reserva %d %s a los %v (plazas %d/%d, mecánicos %d/%d)", r.ID, r.Categoria, r.En, nPlazas, capPlazas, nMecanicos, capMecanicos)}
+
+			// A su hora, la cita entra en fase 0 como un coche más.
+			s.vida.lanzar(func() {
+				if d := r.En - simSince(s.start); d > 0 {
+					s.vida.dormir(d)
+				}
+				c := s.AddCoche(r.Categoria)
+				enviar(s.vida, citas, citaReq{reserva: r, cocheID: c.ID})
+			})
+			req.reply <- reservaResp{r: r}
+
+		case c := <-citas:
+			for i := range reservas {
+				if reservas[i].ID == c.reserva.ID {
+					reservas[i].CocheID = c.cocheID
+				}
+			}
+			s.logs <- LogEvent{Elapsed: simSince(s.start), CocheID: c.cocheID, Incidencia: categoriaTipo(c.reserva.Categoria), Estado: EstadoCita,
+				Detalle: fmt.Sprintf("llega la reserva %d", c.reserva.ID)}
+
+		case reply := <-s.reservasQ:
+			reply <- append([]Reserva{}, reservas...)
+		}
+	}
+}
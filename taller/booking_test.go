@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Con 1 plaza y 1 mecánico, una cita solapada con otra se rechaza salvo que
+// haya margen de sobreventa; una cita que encaja justo detrás se acepta.
+func TestAgenda_CapacidadYSobreventa(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	for _, tc := range []struct {
+		name       string
+		sobreventa float64
+		solapada   bool // se acepta la cita solapada
+	}{
+		{"SIN_SOBREVENTA", 0, false},
+		{"SOBREVENTA_100", 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := make(chan LogEvent, 64)
+			go func() {
+				for range logs {
+				}
+			}()
+
+			cfg := DefaultConfig()
+			cfg.NumA, cfg.NumB, cfg.NumC = 0, 0, 0
+			cfg.NumPlazas, cfg.NumMecanicos = 1, 1
+			cfg.Sobreventa = tc.sobreventa
+			s := arrancar(t, time.Now(), logs, cfg)
+
+			// A ocupa plaza [1h, 1h+5s) y mecánico [1h+5s, 1h+10s).
+			base := time.Hour
+			if _, err := s.Reservar(CatA, base); err != nil {
+				t.Fatalf("primera cita: %v", err)
+			}
+
+			r, err := s.Reservar(CatA, base+2*time.Second)
+			if tc.solapada {
+				if err != nil || !r.Sobreventa {
+					t.Fatalf("esperaba sobreventa, got %+v, %v", r, err)
+				}
+			} else if err == nil {
+				t.Fatalf("esperaba rechazo de la cita solapada, got %+v", r)
+			}
+
+			// C justo cuando se libera la plaza: plaza [1h+5s, 1h+6s), mecánico [1h+6s, 1h+7s)
+			// solapa con el mecánico de la primera, así que solo cabe en sobreventa.
+			r, err = s.Reservar(CatC, base+5*time.Second)
+			if tc.solapada != (err == nil) {
+				t.Fatalf("cita C: got %+v, %v", r, err)
+			}
+
+			// Una cita pasada siempre se rechaza.
+			if _, err := s.Reservar(CatB, 0); err == nil {
+				t.Fatal("se aceptó una cita en el pasado")
+			}
+		})
+	}
+}
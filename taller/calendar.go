@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tramo es un intervalo [Desde, Hasta) de horas del día (p.ej. 8h a 14h).
+// Si Hasta es menor que Desde, el tramo cruza la medianoche (22h a 6h).
+type Tramo struct {
+	Desde time.Duration
+	Hasta time.Duration
+}
+
+// Turno son los tramos en que se trabaja dentro del día. Un turno de mañana
+// y tarde con descanso para comer: {{8h, 14h}, {15h, 19h}}.
+// Un turno nil es "siempre disponible".
+type Turno []Tramo
+
+// Contiene indica si la hora del día cae dentro de algún tramo.
+func (t Turno) Contiene(hora time.Duration) bool {
+	if t == nil {
+		return true
+	}
+	for _, tr := range t {
+		if tr.Desde <= tr.Hasta && hora >= tr.Desde && hora < tr.Hasta {
+			return true
+		}
+		if tr.Desde > tr.Hasta && (hora >= tr.Desde || hora < tr.Hasta) {
+			return true
+		}
+	}
+	return false
+}
+
+// CalendarConfig configura horarios sobre el reloj de simulación.
+type CalendarConfig struct {
+	// Lo que dura un día de 24h en tiempo de simulación (0 = sin calendario:
+	// taller y workers siempre disponibles).
+	DuracionDia time.Duration
+
+	// Hora del día a la que arranca la simulación.
+	HoraInicio time.Duration
+
+	// Horario de apertura del taller, independiente del Cerrado remoto.
+	Apertura Turno
+
+	// Turno de cada worker por recurso, con el mismo hueco que en Perfiles:
+	// al arrancar, el primero que no tenga otro worker vivo.
+	// Los workers sin turno configurado están siempre disponibles.
+	Turnos map[string][]Turno
+}
+
+// DefaultCalendario es un horario de ejemplo para la ejecución manual:
+// taller abierto de 8h a 20h, un mecánico de mañana y tarde con descanso
+// para comer y otro de tarde. Limpieza y entrega siguen el horario del taller.
+func DefaultCalendario(duracionDia time.Duration) CalendarConfig {
+	return CalendarConfig{
+		DuracionDia: duracionDia,
+		HoraInicio:  8 * time.Hour,
+		Apertura:    Turno{{8 * time.Hour, 20 * time.Hour}},
+		Turnos: map[string][]Turno{
+			RecursoMecanicos: {
+				{{8 * time.Hour, 14 * time.Hour}, {15 * time.Hour, 19 * time.Hour}},
+				{{12 * time.Hour, 20 * time.Hour}},
+			},
+		},
+	}
+}
+
+// Calendario traduce el reloj de simulación a hora del día.
+type Calendario struct {
+	start time.Time
+	cfg   CalendarConfig
+}
+
+// Hora devuelve la hora del día simulado (0..24h).
+func (c Calendario) Hora() time.Duration {
+	if c.cfg.DuracionDia <= 0 {
+		return 0
+	}
+	dia := 24 * time.Hour
+	enDia := simSince(c.start) % c.cfg.DuracionDia
+	hora := c.cfg.HoraInicio + time.Duration(float64(enDia)/float64(c.cfg.DuracionDia)*float64(dia))
+	return hora % dia
+}
+
+// Abierto indica si el taller está en horario de apertura.
+func (c Calendario) Abierto() bool {
+	return c.Disponible(c.cfg.Apertura)
+}
+
+// Disponible indica si un turno está en horas de trabajo ahora mismo.
+func (c Calendario) Disponible(t Turno) bool {
+	if c.cfg.DuracionDia <= 0 {
+		return true
+	}
+	return t.Contiene(c.Hora())
+}
+
+// turnoWorker devuelve el turno del worker n (1, 2, ...) de un recurso.
+func (c Calendario) turnoWorker(recurso string, n int) Turno {
+	ts := c.cfg.Turnos[recurso]
+	if n < 1 || n > len(ts) {
+		return nil
+	}
+	return ts[n-1]
+}
+
+// formatHora muestra una hora del día como hh:mm.
+func formatHora(h time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(h.Hours()), int(h.Minutes())%60)
+}
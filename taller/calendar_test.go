@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Un turno de mañana con descanso y otro de noche que cruza la medianoche.
+func TestTurno_Contiene(t *testing.T) {
+	partido := Turno{{8 * time.Hour, 14 * time.Hour}, {15 * time.Hour, 19 * time.Hour}}
+	noche := Turno{{22 * time.Hour, 6 * time.Hour}}
+
+	for _, tc := range []struct {
+		turno Turno
+		hora  time.Duration
+		want  bool
+	}{
+		{partido, 8 * time.Hour, true},
+		{partido, 14 * time.Hour, false}, // [Desde, Hasta)
+		{partido, 14*time.Hour + 30*time.Minute, false},
+		{partido, 18*time.Hour + 59*time.Minute, true},
+		{noche, 21*time.Hour + 59*time.Minute, false},
+		{noche, 22 * time.Hour, true},
+		{noche, 0, true},
+		{noche, 5*time.Hour + 59*time.Minute, true},
+		{noche, 6 * time.Hour, false},
+		{nil, 3 * time.Hour, true}, // sin turno, siempre
+	} {
+		if got := tc.turno.Contiene(tc.hora); got != tc.want {
+			t.Errorf("%v contiene %s: %v, quería %v", tc.turno, formatHora(tc.hora), got, tc.want)
+		}
+	}
+}
+
+// Con un día de 24s que empieza a las 20h, a los 6s son las 2h del día
+// siguiente y el taller de noche está abierto; a los 11s (7h), cerrado.
+func TestCalendario_HoraDaLaVuelta(t *testing.T) {
+	var pasado time.Duration
+	since := simSince
+	simSince = func(time.Time) time.Duration { return pasado }
+	t.Cleanup(func() { simSince = since })
+
+	cal := Calendario{cfg: CalendarConfig{
+		DuracionDia: 24 * time.Second,
+		HoraInicio:  20 * time.Hour,
+		Apertura:    Turno{{22 * time.Hour, 6 * time.Hour}},
+		Turnos:      map[string][]Turno{RecursoMecanicos: {{{23 * time.Hour, 3 * time.Hour}}}},
+	}}
+
+	for _, tc := range []struct {
+		pasado  time.Duration
+		hora    time.Duration
+		abierto bool
+	}{
+		{0, 20 * time.Hour, false},
+		{3 * time.Second, 23 * time.Hour, true},
+		{6 * time.Second, 2 * time.Hour, true},
+		{11 * time.Second, 7 * time.Hour, false},
+		{27 * time.Second, 23 * time.Hour, true}, // segundo día
+	} {
+		pasado = tc.pasado
+		if got := cal.Hora(); got != tc.hora {
+			t.Errorf("a los %v: %s, quería %s", tc.pasado, formatHora(got), formatHora(tc.hora))
+		}
+		if got := cal.Abierto(); got != tc.abierto {
+			t.Errorf("a los %v: abierto %v, quería %v", tc.pasado, got, tc.abierto)
+		}
+	}
+
+	// El mecánico 1 tiene el turno de 23h a 3h; el 2 no tiene turno.
+	pasado = 6 * time.Second
+	if !cal.Disponible(cal.turnoWorker(RecursoMecanicos, 1)) {
+		t.Error("mecánico 1 fuera de turno a las 02:00")
+	}
+	pasado = 11 * time.Second
+	if cal.Disponible(cal.turnoWorker(RecursoMecanicos, 1)) {
+		t.Error("mecánico 1 en turno a las 07:00")
+	}
+	if cal.turnoWorker(RecursoMecanicos, 2) != nil || cal.turnoWorker(RecursoMecanicos, 0) != nil {
+		t.Error("turno para un worker sin turno configurado")
+	}
+	if !cal.Disponible(cal.turnoWorker(RecursoMecanicos, 2)) {
+		t.Error("un worker sin turno no está siempre disponible")
+	}
+}
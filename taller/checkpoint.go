@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Ubicacion es dónde está un coche vivo (entregado = ya no está).
+type Ubicacion struct {
+	Coche      Coche `json:"coche"` // Coche.Resto = trabajo pendiente en la fase
+	Fase       int   `json:"fase"`
+	EnServicio bool  `json:"enServicio,omitempty"` // false = en la cola de Fase (en fase 0, esperando plaza)
+}
+
+// Checkpoint es una foto de la simulación para poder retomarla si el proceso muere.
+// No incluye inventario ni reservas. De la traza de llegadas solo guarda
+// cuántas han entrado ya: al retomar, se sigue con las demás.
+type Checkpoint struct {
+	Tiempo      time.Duration           `json:"tiempo"` // reloj de simulación al hacer la foto
+	Estado      TallerState             `json:"estado"`
+	SiguienteID int                     `json:"siguienteId"`
+	Llegadas    int                     `json:"llegadas,omitempty"` // de la traza, ya dadas de alta
+	Capacidades map[string]int          `json:"capacidades"`        // pedidas por Config/API (sin restar ausencias)
+	Recursos    map[string]PoolSnapshot `json:"recursos"`
+	Coches      []Ubicacion             `json:"coches"` // los de cada cola, en el orden de la cola
+}
+
+// Registro sabe dónde está cada coche vivo. Lo actualizan la fase 0 y los
+// workers en cada cambio (y a cada trozo de servicio). Es un actor más: una
+// goroutine dueña del mapa y peticiones por canales.
+type Registro struct {
+	vida *vida
+
+	set   chan Ubicacion
+	del   chan int
+	todos chan chan map[int]Ubicacion
+}
+
+func newRegistro(v *vida) *Registro {
+	r := &Registro{
+		vida:  v,
+		set:   make(chan Ubicacion),
+		del:   make(chan int),
+		todos: make(chan chan map[int]Ubicacion),
+	}
+	v.lanzar(r.loop)
+	return r
+}
+
+// EnCola apunta que el coche espera en la cola de la fase (o plaza, en fase 0).
+func (r *Registro) EnCola(c Coche, fase int) {
+	if r != nil {
+		enviar(r.vida, r.set, Ubicacion{Coche: c, Fase: fase})
+	}
+}
+
+// EnServicio apunta que al coche le queda resto de trabajo en la fase.
+func (r *Registro) EnServicio(c Coche, fase int, resto time.Duration) {
+	if r != nil {
+		c.Resto = resto
+		enviar(r.vida, r.set, Ubicacion{Coche: c, Fase: fase, EnServicio: true})
+	}
+}
+
+// Entregado olvida un coche que ya ha salido del taller.
+func (r *Registro) Entregado(id int) {
+	if r != nil {
+		enviar(r.vida, r.del, id)
+	}
+}
+
+// Todos devuelve una copia de las ubicaciones.
+func (r *Registro) Todos() map[int]Ubicacion {
+	reply := make(chan map[int]Ubicacion, 1)
+	enviar(r.vida, r.todos, reply)
+	return recibir(r.vida, reply)
+}
+
+func (r *Registro) loop() {
+	coches := map[int]Ubicacion{}
+	for {
+		select {
+		case <-r.vida.parada():
+			return
+		case u := <-r.set:
+			coches[u.Coche.ID] = u
+		case id := <-r.del:
+			delete(coches, id)
+		case reply := <-r.todos:
+			cp := make(map[int]Ubicacion, len(coches))
+			for id, u := range coches {
+				cp[id] = u
+			}
+			reply <- cp
+		}
+	}
+}
+
+// Checkpoint hace la foto de la simulación en este momento. Lo que se
+// retoma (coches, siguiente ID, capacidades) sale de una sola foto del loop;
+// las colas se miran después solo para ordenar a los que esperan, y un
+// coche que ya no esté en su cola va detrás con los demás. Estado y
+// Recursos son informativos y pueden ser de un instante algo posterior.
+func (s *Simulation) Checkpoint() Checkpoint {
+	foto := s.fotoLoop()
+	todos := foto.coches
+	cp := Checkpoint{
+		Tiempo:      simSince(s.start),
+		Estado:      stateProvider(),
+		SiguienteID: foto.siguienteID,
+		Llegadas:    foto.llegadas,
+		Capacidades: foto.capacidades,
+		Recursos:    s.Recursos(),
+	}
+
+	// Primero los que están en cola, en el orden de su cola...
+	for fase, q := range map[int]*PhaseQueue{FaseMecanico: s.q1, FaseLimpieza: s.q2, FaseEntrega: s.q3} {
+		snap := q.Snapshot()
+		for _, l := range [][]Coche{snap.A, snap.B, snap.C} {
+			for _, c := range l {
+				if u, ok := todos[c.ID]; ok && u.Fase == fase && !u.EnServicio {
+					cp.Coches = append(cp.Coches, u)
+					delete(todos, c.ID)
+				}
+			}
+		}
+	}
+	// ...y después el resto (en servicio, esperando plaza, de paso entre fases).
+	resto := make([]Ubicacion, 0, len(todos))
+	for _, u := range todos {
+		resto = append(resto, u)
+	}
+	sort.Slice(resto, func(i, j int) bool { return resto[i].Coche.ID < resto[j].Coche.ID })
+	cp.Coches = append(cp.Coches, resto...)
+	return cp
+}
+
+// guardarCheckpoints escribe un checkpoint en path cada cierto tiempo. Se
+// escribe aparte y se renombra, así el fichero siempre tiene una foto entera.
+func (s *Simulation) guardarCheckpoints(path string, cada time.Duration) {
+	for {
+		s.vida.dormir(cada)
+		if err := GuardarCheckpoint(path, s.Checkpoint()); err != nil {
+			log.Println("checkpoint:", err)
+		}
+	}
+}
+
+// GuardarCheckpoint escribe el checkpoint en path (JSON).
+func GuardarCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadCheckpoint lee un checkpoint escrito por GuardarCheckpoint.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+	return &cp, nil
+}
+
+// restaurar vuelve a poner cada coche del checkpoint donde estaba. Los que
+// estaban en servicio vuelven al principio de su cola con el trabajo que les
+// quedaba (Coche.Resto); los de fase 0 vuelven a su plaza.
+func (s *Simulation) restaurar(cp *Checkpoint) {
+	colas := map[int]*PhaseQueue{FaseMecanico: s.q1, FaseLimpieza: s.q2, FaseEntrega: s.q3}
+	porCola := map[int][]Coche{}
+
+	for _, u := range cp.Coches {
+		c := u.Coche
+		s.reg.EnCola(c, u.Fase)
+		if u.Fase == FaseEsperaPlaza {
+			s.vida.lanzar(func() { fase0Plaza(s.start, s.plazaSpec(), c, s.logs) })
+			continue
+		}
+		if u.EnServicio {
+			// Delante de los que esperaban (EnqueueFront no espera hueco).
+			colas[u.Fase].EnqueueFront(c)
+			continue
+		}
+		porCola[u.Fase] = append(porCola[u.Fase], c)
+	}
+
+	// El resto, en orden; si no caben, esperan hueco como cualquier otro.
+	for fase, cs := range porCola {
+		q, cs := colas[fase], cs
+		s.vida.lanzar(func() {
+			for _, c := range cs {
+				q.Enqueue(c)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Un checkpoint guardado en fichero se lee igual, y el fichero solo se
+// sustituye entero.
+func TestCheckpoint_Fichero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taller.ckpt")
+	cp := Checkpoint{
+		Tiempo:      42 * time.Second,
+		Estado:      TallerState{Activo: true, SoloCategoria: CatB, MecanicosFuera: 1},
+		SiguienteID: 7,
+		Capacidades: map[string]int{RecursoPlazas: 4, RecursoMecanicos: 2},
+		Recursos:    map[string]PoolSnapshot{RecursoMecanicos: {Capacidad: 2, Ocupados: 1}},
+		Coches: []Ubicacion{
+			{Coche: Coche{ID: 3, Categoria: CatA, Plazo: time.Minute, Coste: 12.5}, Fase: FaseMecanico},
+			{Coche: Coche{ID: 5, Categoria: CatC, Urgente: true, Resto: 1500 * time.Millisecond}, Fase: FaseLimpieza, EnServicio: true},
+			{Coche: Coche{ID: 6, Categoria: CatB}, Fase: FaseEsperaPlaza},
+		},
+	}
+	if err := GuardarCheckpoint(path, cp); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*got, cp) {
+		t.Fatalf("leído %+v\nguardado %+v", *got, cp)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("queda el temporal: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{no es json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCheckpoint(path); err == nil {
+		t.Fatal("un checkpoint roto debería dar error")
+	}
+	if _, err := LoadCheckpoint(path + ".no"); err == nil {
+		t.Fatal("un checkpoint que no existe debería dar error")
+	}
+}
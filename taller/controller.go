@@ -1,25 +1,68 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
 
 type stateRequest struct {
 	reply chan TallerState
 }
 
 // controller mantiene el TallerState actualizado y permite consultarlo.
-// - codes: stream de 0..9 desde la mutua
+// - fusion: estado de partida y cómo combinar el de varias fuentes
+// - codes: stream de 0..9 desde la mutua (más los códigos ampliados)
 // - queries: peticiones de “dame el estado actual”
-func controller(codes <-chan int, queries <-chan stateRequest) {
-	state := defaultState()
-
+//
+// Los códigos que llegan en un sobre se confirman (Ack) tras aplicarlos; los
+// duplicados o que llegan tarde se confirman sin aplicarlos. Si el sobre
+// trae un lease, el estado de esa fuente caduca cuando vence sin renovar.
+func controller(fusion *Fusion, codes <-chan Codigo, queries <-chan stateRequest) {
+	state := fusion.Estado()
+	secuencias := Secuencias{}
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
 	for {
 		select {
-		case code, ok := <-codes:
+		case c, ok := <-codes:
 			if !ok {
 				return
 			}
-			state.applyCode(code)
-			fmt.Println("ESTADO ACTUAL:", stateSummary(state)) // debug temporal
+			if c.Sobre != nil {
+				if r := secuencias.Comprobar(*c.Sobre); r != sobres.ResultadoAplicado {
+					debugln("SOBRE", r+":", c.Sobre.ID, "de", c.Sobre.Emisor)
+					confirmarSobre(*c.Sobre, state, r)
+					continue
+				}
+			}
+			code := c.N
+			var hasta time.Time
+			if c.Sobre != nil && c.Sobre.Lease > 0 {
+				hasta = time.Now().Add(c.Sobre.Lease)
+			}
+			state = fusion.Aplicar(c.fuente(), code, hasta)
+			estado := state
+			walLog.Anotar(EventoWAL{Tipo: WALCodigo, Codigo: &code, Estado: &estado})
+			debugln("ESTADO ACTUAL:", stateSummary(state)) // debug temporal
+			if c.Sobre != nil && c.Sobre.Motivo != "" {
+				debugln("  motivo:", c.Sobre.Motivo)
+			}
+			if c.Sobre != nil {
+				confirmarSobre(*c.Sobre, state, sobres.ResultadoAplicado)
+			}
+
+		case ahora := <-tick.C:
+			fuera := fusion.Caducar(ahora)
+			if len(fuera) == 0 {
+				continue
+			}
+			state = fusion.Estado()
+			estado := state
+			walLog.Anotar(EventoWAL{Tipo: WALCodigo, Estado: &estado})
+			debugln("CADUCA sin renovar el estado de", strings.Join(fuera, ", ")+"; queda", stateSummary(state))
 
 		case req := <-queries:
 			req.reply <- state
@@ -28,6 +71,17 @@ func controller(codes <-chan int, queries <-chan stateRequest) {
 }
 
 func stateSummary(s TallerState) string {
+	resumen := stateBase(s)
+	if s.MecanicosFuera > 0 {
+		resumen += fmt.Sprintf(" (-%d mecánicos)", s.MecanicosFuera)
+	}
+	if s.Fuente != "" {
+		resumen += " [" + s.Fuente + "]"
+	}
+	return resumen
+}
+
+func stateBase(s TallerState) string {
 	if s.Cerrado {
 		return "CERRADO"
 	}
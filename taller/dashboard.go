@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Refresco de la pantalla en modo dashboard.
+const dashboardRefresh = 500 * time.Millisecond
+
+// Nº de eventos recientes que se muestran al pie.
+const dashboardUltimos = 8
+
+// Secuencias ANSI: cursor arriba a la izquierda y borrar pantalla.
+const (
+	ansiHome  = "\033[H"
+	ansiClear = "\033[2J"
+)
+
+// runDashboard sustituye a runLogger en modo -dashboard: consume los LogEvent
+// para llevar contadores y repinta en el sitio el estado del taller, las colas,
+// la ocupación de los recursos y el throughput. Igual que runLogger, lleva
+// el Informe de la ejecución y lo entrega a quien lo pida por informes.
+func runDashboard(logs <-chan LogEvent, informes <-chan chan string, s *Simulation, start time.Time) {
+	inf := NewInforme()
+	entran := make([]int, FaseEntrega+1)
+	salen := make([]int, FaseEntrega+1)
+	var ultimos []string
+
+	ticker := time.NewTicker(dashboardRefresh)
+	defer ticker.Stop()
+
+	fmt.Print(ansiClear)
+	for {
+		select {
+		case ev, ok := <-logs:
+			if !ok {
+				return
+			}
+			inf.Observar(ev)
+			switch ev.Estado {
+			case EstadoEntra:
+				entran[ev.Fase]++
+			case EstadoSale:
+				salen[ev.Fase]++
+			}
+			ultimos = append(ultimos, formatLogEvent(ev))
+			if len(ultimos) > dashboardUltimos {
+				ultimos = ultimos[1:]
+			}
+
+		case reply := <-informes:
+			reply <- inf.String()
+
+		case <-ticker.C:
+			fmt.Print(ansiHome + ansiClear + renderDashboard(tomarFotoPanel(s, start), inf, entran, salen, ultimos))
+		}
+	}
+}
+
+// fotoPanel es lo que pinta el panel en un refresco. Horario queda vacío
+// si no hay calendario.
+type fotoPanel struct {
+	elapsed  time.Duration
+	estado   TallerState
+	hora     time.Duration
+	horario  string
+	recursos map[string]PoolSnapshot
+	colas    map[string]QueueSnapshot
+}
+
+func tomarFotoPanel(s *Simulation, start time.Time) fotoPanel {
+	f := fotoPanel{
+		elapsed:  time.Since(start),
+		estado:   getState(),
+		recursos: s.Recursos(),
+		colas:    s.Colas(),
+	}
+	if s.cfg.Calendario.DuracionDia > 0 {
+		f.hora, f.horario = s.cal.Hora(), "ABIERTO"
+		if !s.cal.Abierto() {
+			f.horario = "FUERA DE HORARIO"
+		}
+	}
+	return f
+}
+
+func renderDashboard(f fotoPanel, inf *Informe, entran, salen []int, ultimos []string) string {
+	var b strings.Builder
+
+	elapsed := f.elapsed
+	fmt.Fprintf(&b, "TALLER  t=%v  estado=%s", elapsed.Truncate(100*time.Millisecond), stateSummary(f.estado))
+	if f.horario != "" {
+		fmt.Fprintf(&b, "  hora=%s %s", formatHora(f.hora), f.horario)
+	}
+	b.WriteString("\n\n")
+
+	// Recursos: ocupados/capacidad y cuántos esperan hueco.
+	fmt.Fprintf(&b, "%-10s %8s %6s %6s %9s %6s %6s\n", "RECURSO", "OCUPADOS", "LIBRES", "CAP", "ESPERANDO", "ENTRAN", "SALEN")
+	for fase, nombre := range []string{RecursoPlazas, RecursoMecanicos, RecursoLimpieza, RecursoEntrega} {
+		p := f.recursos[nombre]
+		libres := p.Capacidad - p.Ocupados
+		if libres < 0 {
+			libres = 0
+		}
+		fmt.Fprintf(&b, "%-10s %8d %6d %6d %9d %6d %6d\n",
+			nombre, p.Ocupados, libres, p.Capacidad, p.Esperando, entran[fase], salen[fase])
+	}
+
+	// Colas: IDs de coche por categoría.
+	fmt.Fprintf(&b, "\nCOLAS\n")
+	for _, nombre := range []string{"fase1", "fase2", "fase3"} {
+		q := f.colas[nombre]
+		fmt.Fprintf(&b, "%-6s %3d/%-3d  A:%s  B:%s  C:%s\n", nombre,
+			len(q.A)+len(q.B)+len(q.C), q.Capacidad, idsCoches(q.A), idsCoches(q.B), idsCoches(q.C))
+	}
+
+	// Throughput: coches entregados por segundo desde el arranque.
+	terminados := salen[FaseEntrega]
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(terminados) / elapsed.Seconds()
+	}
+	fmt.Fprintf(&b, "\nTERMINADOS %d  throughput=%.2f coches/s  retrabajos=%d\n", terminados, throughput, inf.Retrabajos)
+
+	fmt.Fprintf(&b, "\nÚLTIMOS EVENTOS\n")
+	for _, l := range ultimos {
+		b.WriteString(l + "\n")
+	}
+	return b.String()
+}
+
+func idsCoches(cs []Coche) string {
+	if len(cs) == 0 {
+		return "-"
+	}
+	ids := make([]string, len(cs))
+	for i, c := range cs {
+		ids[i] = fmt.Sprint(c.ID)
+	}
+	return "[" + strings.Join(ids, " ") + "]"
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// El panel pinta el estado, la ocupación de cada recurso con los coches que
+// entran y salen de cada fase, las colas por categoría y el throughput.
+func TestRenderDashboard(t *testing.T) {
+	recursos := map[string]PoolSnapshot{
+		RecursoPlazas:    {Capacidad: 6, Ocupados: 6, Esperando: 2},
+		RecursoMecanicos: {Capacidad: 3, Ocupados: 1},
+		RecursoLimpieza:  {Capacidad: 1},
+		RecursoEntrega:   {Capacidad: 1, Ocupados: 2}, // encogido: no hay libres negativos
+	}
+	colas := map[string]QueueSnapshot{
+		"fase1": {Capacidad: 6, A: []Coche{{ID: 3}, {ID: 7}}, C: []Coche{{ID: 4}}},
+		"fase2": {Capacidad: 6},
+		"fase3": {Capacidad: 6, B: []Coche{{ID: 1}}},
+	}
+	entran := []int{8, 5, 3, 2}
+	salen := []int{6, 4, 2, 2}
+
+	for _, tc := range []struct {
+		nombre string
+		foto   fotoPanel
+		want   []string
+		nada   []string
+	}{
+		{
+			nombre: "normal",
+			foto:   fotoPanel{elapsed: 4*time.Second + 30*time.Millisecond, estado: TallerState{Activo: true, PrioridadCategoria: "A", Fuente: "norte"}},
+			want: []string{
+				"TALLER  t=4s  estado=PRIORIDAD A [norte]\n",
+				"plazas            6      0      6         2      8      6\n",
+				"mecanicos         1      2      3         0      5      4\n",
+				"entrega           2      0      1         0      2      2\n",
+				"fase1    3/6    A:[3 7]  B:-  C:[4]\n",
+				"fase2    0/6    A:-  B:-  C:-\n",
+				"fase3    1/6    A:-  B:[1]  C:-\n",
+				"TERMINADOS 2  throughput=0.50 coches/s  retrabajos=0\n",
+				"ÚLTIMOS EVENTOS\n[1.0s] coche 3 entra\n",
+			},
+			nada: []string{"hora="},
+		},
+		{
+			nombre: "cerrado fuera de horario",
+			foto:   fotoPanel{elapsed: 10 * time.Second, estado: TallerState{Cerrado: true, MecanicosFuera: 1}, hora: 21*time.Hour + 5*time.Minute, horario: "FUERA DE HORARIO"},
+			want: []string{
+				"estado=CERRADO (-1 mecánicos)  hora=21:05 FUERA DE HORARIO\n",
+				"throughput=0.20 coches/s",
+			},
+		},
+	} {
+		tc.foto.recursos, tc.foto.colas = recursos, colas
+		got := renderDashboard(tc.foto, NewInforme(), entran, salen, []string{"[1.0s] coche 3 entra"})
+		for _, w := range tc.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: falta %q en\n%s", tc.nombre, w, got)
+			}
+		}
+		for _, w := range tc.nada {
+			if strings.Contains(got, w) {
+				t.Errorf("%s: sobra %q en\n%s", tc.nombre, w, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Qué hacer con el coche cuando el worker se avería a mitad de servicio.
+const (
+	AveriaPausa   = "pausa"   // el coche espera a la reparación y se sigue donde se dejó
+	AveriaAbortar = "abortar" // el coche vuelve al principio de su cola y se empieza de cero
+)
+
+// AveriasConfig configura averías aleatorias de los workers de las fases 1..3.
+// Los tiempos son de simulación y se cuentan sobre el tiempo trabajando.
+type AveriasConfig struct {
+	// Tiempo medio entre averías y tiempo medio de reparación por recurso.
+	// Un recurso sin MTBF (o con 0) nunca se avería.
+	MTBF map[string]time.Duration
+	MTTR map[string]time.Duration
+
+	Modo string // AveriaPausa (por defecto) o AveriaAbortar
+}
+
+// averiaWorker lleva la cuenta de averías de un worker concreto.
+// Solo lo usa su worker, así que no necesita goroutine propia.
+type averiaWorker struct {
+	mtbf    time.Duration
+	mttr    time.Duration
+	abortar bool
+
+	hasta time.Duration // tiempo de trabajo que queda hasta la próxima avería
+}
+
+// newAveriaWorker devuelve nil si el recurso no se avería.
+func newAveriaWorker(cfg AveriasConfig, recurso string) *averiaWorker {
+	mtbf := cfg.MTBF[recurso]
+	if mtbf <= 0 {
+		return nil
+	}
+	a := &averiaWorker{mtbf: mtbf, mttr: cfg.MTTR[recurso], abortar: cfg.Modo == AveriaAbortar}
+	a.hasta = expDur(a.mtbf)
+	return a
+}
+
+// paso recorta un trozo de trabajo para no pasarse de la próxima avería y
+// devuelve si al terminarlo el worker se avería.
+func (a *averiaWorker) paso(d time.Duration) (time.Duration, bool) {
+	if a == nil {
+		return d, false
+	}
+	if a.hasta <= d {
+		d = a.hasta
+		a.hasta = 0
+		return d, true
+	}
+	a.hasta -= d
+	return d, false
+}
+
+// reparar devuelve lo que tarda la reparación y programa la siguiente avería.
+func (a *averiaWorker) reparar() time.Duration {
+	a.hasta = expDur(a.mtbf)
+	return expDur(a.mttr)
+}
+
+// expDur: duración aleatoria con distribución exponencial de media mean.
+func expDur(mean time.Duration) time.Duration {
+	if mean <= 0 {
+		return 0
+	}
+	return time.Duration(rand.ExpFloat64() * float64(mean))
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	fusion      = flag.String("fusion", FusionUltimo, "cómo combinar el estado de varias mutuas: ultimo, precedencia o restrictivo")
+	precedencia = flag.String("precedencia", "", "con -fusion precedencia, fuentes de más a menos importante, p.ej. mutua-norte,mutua-sur")
+	defecto     = flag.Int("defecto", -1, "código (0..9) del estado al que se vuelve cuando no queda ninguna fuente vigente; -1 = el normal (activo, sin restricciones)")
+)
+
+// Políticas para combinar el estado que pide cada fuente.
+const (
+	FusionUltimo      = "ultimo"      // manda la última fuente que ha escrito
+	FusionPrecedencia = "precedencia" // manda la fuente más alta de la lista
+	FusionRestrictivo = "restrictivo" // manda el estado más restrictivo
+)
+
+// Fuentes de los códigos que no llegan en un sobre con emisor.
+const (
+	FuenteMutua = "mutua" // código suelto por TCP (la mutua de siempre)
+	FuenteAPI   = "api"   // POST /estado
+)
+
+// fuente es de quién viene el código: el emisor del sobre ("emisor/tema"
+// si trae tema), o la que se indicó al crearlo.
+func (c Codigo) fuente() string {
+	switch {
+	case c.Sobre != nil && c.Sobre.Emisor != "" && c.Sobre.Tema != "":
+		return c.Sobre.Emisor + "/" + c.Sobre.Tema
+	case c.Sobre != nil && c.Sobre.Emisor != "":
+		return c.Sobre.Emisor
+	case c.Fuente != "":
+		return c.Fuente
+	}
+	return FuenteMutua
+}
+
+// Fusion lleva el estado que ha pedido cada fuente y decide cuál manda.
+// Es del controlador. Los mecánicos que se van (10/11) no son de ninguna
+// fuente: se suman a lo que salga. Si una fuente pidió su estado con un
+// lease y no lo renueva, caduca y deja de contar.
+type Fusion struct {
+	politica string
+	orden    map[string]int // posición en -precedencia (0 = la más alta)
+	base     TallerState    // estado del que parte una fuente nueva y al que se vuelve
+	estados  map[string]TallerState
+	escrito  map[string]int       // cuándo escribió cada fuente por última vez
+	caduca   map[string]time.Time // solo las fuentes con lease
+	reloj    int
+	fuera    int
+}
+
+// NuevaFusion prepara la fusión partiendo de base: el estado mientras no
+// escriba ninguna fuente y cuando caducan todas.
+func NuevaFusion(politica string, orden []string, base TallerState) (*Fusion, error) {
+	switch politica {
+	case FusionUltimo, FusionPrecedencia, FusionRestrictivo:
+	default:
+		return nil, fmt.Errorf("fusion: política desconocida %q (ultimo, precedencia o restrictivo)", politica)
+	}
+	f := &Fusion{
+		politica: politica,
+		orden:    map[string]int{},
+		estados:  map[string]TallerState{},
+		escrito:  map[string]int{},
+		caduca:   map[string]time.Time{},
+		fuera:    base.MecanicosFuera,
+	}
+	for i, nombre := range orden {
+		f.orden[nombre] = i
+	}
+	base.MecanicosFuera, base.Fuente = 0, ""
+	f.base = base
+	return f, nil
+}
+
+// Restaurar parte del estado st guardado en un checkpoint, sin tocar la
+// base. Vuelve a ser el de su fuente, sin lease (no se guarda). Si no era
+// de ninguna, vale hasta que escriba alguna, y entonces se vuelve a la base.
+func (f *Fusion) Restaurar(st TallerState) {
+	f.fuera = st.MecanicosFuera
+	fuente := st.Fuente
+	st.MecanicosFuera, st.Fuente = 0, ""
+	f.estados[fuente] = st
+}
+
+// Aplicar anota el código de la fuente y devuelve el estado resultante. El
+// estado de la fuente vale hasta la hora dada (cero = sin lease, para siempre).
+func (f *Fusion) Aplicar(fuente string, code int, hasta time.Time) TallerState {
+	switch code {
+	case CodigoMecanicoSeVa:
+		f.fuera++
+	case CodigoMecanicoVuelve:
+		if f.fuera > 0 {
+			f.fuera--
+		}
+	case 7, 8:
+		// No cambian nada, tampoco quién escribió el último.
+	default:
+		delete(f.estados, "") // el restaurado sin fuente ya no cuenta
+		st, ok := f.estados[fuente]
+		if !ok {
+			st = f.base
+		}
+		st.applyCode(code)
+		f.estados[fuente] = st
+		f.reloj++
+		f.escrito[fuente] = f.reloj
+		if hasta.IsZero() {
+			delete(f.caduca, fuente)
+		} else {
+			f.caduca[fuente] = hasta
+		}
+	}
+	return f.Estado()
+}
+
+// Caducar olvida las fuentes cuyo lease ha vencido antes de ahora y dice
+// cuáles eran (ordenadas).
+func (f *Fusion) Caducar(ahora time.Time) []string {
+	var fuera []string
+	for fuente, hasta := range f.caduca {
+		if ahora.After(hasta) {
+			delete(f.caduca, fuente)
+			delete(f.estados, fuente)
+			delete(f.escrito, fuente)
+			fuera = append(fuera, fuente)
+		}
+	}
+	sort.Strings(fuera)
+	return fuera
+}
+
+// Estado es el estado de la fuente que manda según la política, con Fuente
+// diciendo cuál es. Si no ha escrito nadie, es el de partida.
+func (f *Fusion) Estado() TallerState {
+	st := f.base
+	ganadora := ""
+	for nombre, e := range f.estados {
+		if ganadora == "" || f.gana(nombre, ganadora) {
+			st, ganadora = e, nombre
+		}
+	}
+	st.Fuente = ganadora
+	st.MecanicosFuera = f.fuera
+	return st
+}
+
+// gana dice si la fuente a manda sobre la b. A igualdad, la que escribió después.
+func (f *Fusion) gana(a, b string) bool {
+	switch f.politica {
+	case FusionPrecedencia:
+		pa, pb := f.posicion(a), f.posicion(b)
+		if pa != pb {
+			return pa < pb
+		}
+	case FusionRestrictivo:
+		ra, rb := restriccion(f.estados[a]), restriccion(f.estados[b])
+		if ra != rb {
+			return ra > rb
+		}
+	}
+	return f.escrito[a] > f.escrito[b]
+}
+
+// posicion en -precedencia; "emisor/tema" va donde su emisor si no está
+// ella misma, y las que no están van detrás de todas.
+func (f *Fusion) posicion(fuente string) int {
+	if i, ok := f.orden[fuente]; ok {
+		return i
+	}
+	emisor, _, _ := strings.Cut(fuente, "/")
+	if i, ok := f.orden[emisor]; ok {
+		return i
+	}
+	return len(f.orden)
+}
+
+// restriccion ordena los estados: CERRADO > INACTIVO > SOLO > PRIORIDAD > NORMAL.
+func restriccion(s TallerState) int {
+	switch {
+	case s.Cerrado:
+		return 4
+	case !s.Activo:
+		return 3
+	case s.SoloCategoria != "":
+		return 2
+	case s.PrioridadCategoria != "":
+		return 1
+	}
+	return 0
+}
+
+// parsePrecedencia lee "mutua-norte,mutua-sur".
+func parsePrecedencia(s string) []string {
+	var out []string
+	for _, nombre := range strings.Split(s, ",") {
+		if nombre = strings.TrimSpace(nombre); nombre != "" {
+			out = append(out, nombre)
+		}
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"sistemasdistribuidos-p4/internal/sobres"
+)
+
+// Dos mutuas: la norte pide PRIORIDAD A y luego la sur pide SOLO B; después
+// la norte cierra. Cada política elige una distinta.
+func TestFusion_Politicas(t *testing.T) {
+	for _, tc := range []struct {
+		politica string
+		tras     [3]string // resumen tras cada código
+	}{
+		{FusionUltimo, [3]string{"PRIORIDAD A [norte]", "SOLO B [sur]", "CERRADO [norte]"}},
+		{FusionPrecedencia, [3]string{"PRIORIDAD A [norte]", "PRIORIDAD A [norte]", "CERRADO [norte]"}},
+		{FusionRestrictivo, [3]string{"PRIORIDAD A [norte]", "SOLO B [sur]", "CERRADO [norte]"}},
+	} {
+		f, err := NuevaFusion(tc.politica, []string{"norte", "sur"}, defaultState())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, paso := range []struct {
+			fuente string
+			code   int
+		}{{"norte", 4}, {"sur", 2}, {"norte", 9}} {
+			if got := stateSummary(f.Aplicar(paso.fuente, paso.code, time.Time{})); got != tc.tras[i] {
+				t.Errorf("%s, paso %d: %q, quería %q", tc.politica, i+1, got, tc.tras[i])
+			}
+		}
+	}
+
+	// Con restrictivo, que la sur vuelva a PRIORIDAD no levanta el cierre de
+	// la norte; los mecánicos que se van cuentan sea quien sea quien lo diga.
+	f, _ := NuevaFusion(FusionRestrictivo, nil, defaultState())
+	f.Aplicar("norte", 9, time.Time{})
+	f.Aplicar("sur", 5, time.Time{})
+	if got := stateSummary(f.Aplicar("sur", CodigoMecanicoSeVa, time.Time{})); got != "CERRADO (-1 mecánicos) [norte]" {
+		t.Errorf("restrictivo: %q", got)
+	}
+
+	if _, err := NuevaFusion("mayoria", nil, defaultState()); err == nil {
+		t.Error("política desconocida aceptada")
+	}
+}
+
+// La sur cierra con un lease de 10 s y lo renueva una vez; al no renovarlo
+// más, caduca y vuelve a mandar la norte (sin lease, no caduca nunca).
+func TestFusion_Lease(t *testing.T) {
+	t0 := time.Now()
+	f, _ := NuevaFusion(FusionUltimo, nil, defaultState())
+	f.Aplicar("norte", 1, time.Time{})
+	f.Aplicar("sur", 9, t0.Add(10*time.Second))
+	f.Aplicar("sur", 9, t0.Add(15*time.Second)) // renovación
+
+	if fuera := f.Caducar(t0.Add(12 * time.Second)); len(fuera) != 0 {
+		t.Fatalf("caducan %v antes de tiempo", fuera)
+	}
+	if got := stateSummary(f.Estado()); got != "CERRADO [sur]" {
+		t.Fatalf("con lease vigente: %q", got)
+	}
+	if fuera := f.Caducar(t0.Add(16 * time.Second)); len(fuera) != 1 || fuera[0] != "sur" {
+		t.Fatalf("caducadas: %v, quería [sur]", fuera)
+	}
+	if got := stateSummary(f.Estado()); got != "SOLO A [norte]" {
+		t.Fatalf("tras caducar: %q", got)
+	}
+	if fuera := f.Caducar(t0.Add(time.Hour)); len(fuera) != 0 {
+		t.Fatalf("caduca %v sin lease", fuera)
+	}
+}
+
+// Cada tema de un emisor es una fuente; en -precedencia cuenta como su
+// emisor salvo que se nombre ella misma.
+func TestFusion_Temas(t *testing.T) {
+	for _, tc := range []struct {
+		c    Codigo
+		want string
+	}{
+		{Codigo{}, FuenteMutua},
+		{Codigo{Fuente: FuenteAPI}, FuenteAPI},
+		{Codigo{Sobre: &sobres.Sobre{Emisor: "norte"}}, "norte"},
+		{Codigo{Sobre: &sobres.Sobre{Emisor: "norte", Tema: "turnos"}}, "norte/turnos"},
+		{Codigo{Sobre: &sobres.Sobre{Tema: "turnos"}}, FuenteMutua},
+	} {
+		if got := tc.c.fuente(); got != tc.want {
+			t.Errorf("%+v: fuente %q, quería %q", tc.c, got, tc.want)
+		}
+	}
+
+	f, _ := NuevaFusion(FusionPrecedencia, []string{"norte/urgente", "sur", "norte"}, defaultState())
+	f.Aplicar("norte/turnos", 4, time.Time{})
+	f.Aplicar("norte/averias", 9, time.Time{})
+	if got := stateSummary(f.Estado()); got != "CERRADO [norte/averias]" {
+		t.Fatalf("dos temas del mismo emisor: %q", got)
+	}
+	f.Aplicar("sur", 2, time.Time{})
+	if got := stateSummary(f.Estado()); got != "SOLO B [sur]" {
+		t.Fatalf("sur va antes que norte: %q", got)
+	}
+	f.Aplicar("norte/urgente", 0, time.Time{})
+	if got := stateSummary(f.Estado()); got != "INACTIVO [norte/urgente]" {
+		t.Fatalf("norte/urgente va antes que sur: %q", got)
+	}
+}
+
+// Con -defecto y -restore: el estado restaurado manda al arrancar, pero al
+// caducar las fuentes se vuelve a la base (-defecto), no a él. Si era de una
+// fuente, sigue siendo suyo.
+func TestFusion_Restaurar(t *testing.T) {
+	base := defaultState()
+	base.applyCode(0) // -defecto 0
+	t0 := time.Now()
+
+	f, _ := NuevaFusion(FusionUltimo, nil, base)
+	f.Restaurar(TallerState{Cerrado: true, MecanicosFuera: 1})
+	if got := stateSummary(f.Estado()); got != "CERRADO (-1 mecánicos)" {
+		t.Fatalf("restaurado sin fuente: %q", got)
+	}
+	f.Aplicar("sur", 4, t0.Add(time.Second))
+	f.Caducar(t0.Add(2 * time.Second))
+	if got := stateSummary(f.Estado()); got != "INACTIVO (-1 mecánicos)" {
+		t.Fatalf("tras caducar sur: %q, quería la base", got)
+	}
+
+	f, _ = NuevaFusion(FusionUltimo, nil, base)
+	f.Restaurar(TallerState{Activo: true, SoloCategoria: "B", Fuente: "norte"})
+	if got := stateSummary(f.Estado()); got != "SOLO B [norte]" {
+		t.Fatalf("restaurado de norte: %q", got)
+	}
+	f.Aplicar("sur", 4, t0.Add(time.Second))
+	f.Caducar(t0.Add(2 * time.Second))
+	if got := stateSummary(f.Estado()); got != "SOLO B [norte]" {
+		t.Fatalf("tras caducar sur: %q, quería el de norte", got)
+	}
+}
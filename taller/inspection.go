@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Inspeccion es un control de calidad al terminar una fase: con probabilidad
+// ProbFallo[categoría] el coche no pasa y vuelve a la cola de la fase VuelveA
+// (la misma u otra anterior), como mucho MaxRetrabajos veces por coche.
+type Inspeccion struct {
+	VuelveA       int
+	ProbFallo     map[string]float64
+	MaxRetrabajos int
+}
+
+// inspector es una Inspeccion ya resuelta para un worker: sabe cómo
+// devolver los coches que no pasan a la fase VuelveA.
+type inspector struct {
+	Inspeccion
+	devolver func(Coche) // sin bloquear
+}
+
+// newInspector prepara la inspección configurada tras la fase indicada.
+// Solo puede haber inspección tras las fases 1 y 2 (tras la entrega el coche
+// ya se ha ido) y el destino tiene que ser una fase no posterior: 0..fase
+// (0 = vuelve a esperar plaza).
+func newInspector(cfg Inspeccion, fase int, devolver func(Coche)) (*inspector, error) {
+	if fase != FaseMecanico && fase != FaseLimpieza {
+		return nil, fmt.Errorf("inspección tras la fase %d: solo puede ir tras la %d o la %d", fase, FaseMecanico, FaseLimpieza)
+	}
+	if cfg.VuelveA < FaseEsperaPlaza || cfg.VuelveA > fase {
+		return nil, fmt.Errorf("inspección tras la fase %d: VuelveA %d no es una fase de 0 a %d", fase, cfg.VuelveA, fase)
+	}
+	return &inspector{Inspeccion: cfg, devolver: devolver}, nil
+}
+
+// revisar decide si el coche pasa la inspección. Si no pasa, le suma un
+// retrabajo y lo registra; devolverlo (con devolver) es cosa del worker.
+func (in *inspector) revisar(start time.Time, fase int, car *Coche, logs chan<- LogEvent) bool {
+	if in == nil || car.Retrabajos >= in.MaxRetrabajos {
+		return true
+	}
+	if rand.Float64() >= in.ProbFallo[car.Categoria] {
+		return true
+	}
+
+	car.Retrabajos++
+	logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: categoriaTipo(car.Categoria), Fase: fase, Estado: EstadoRetrabajo,
+		Detalle: fmt.Sprintf("no pasa la inspección, vuelve a fase %d (retrabajo %d/%d)", in.VuelveA, car.Retrabajos, in.MaxRetrabajos)}
+	return false
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Solo hay inspección tras las fases 1 y 2, y vuelve a una fase de 0 a la
+// inspeccionada; lo demás es un error, no una inspección que no hace nada.
+func TestNewInspector(t *testing.T) {
+	for _, tc := range []struct {
+		fase, vuelveA int
+		ok            bool
+	}{
+		{FaseMecanico, FaseMecanico, true},
+		{FaseMecanico, FaseEsperaPlaza, true},
+		{FaseLimpieza, FaseMecanico, true},
+		{FaseLimpieza, FaseLimpieza, true},
+		{FaseLimpieza, FaseEntrega, false}, // hacia delante
+		{FaseLimpieza, -1, false},
+		{FaseEntrega, FaseMecanico, false}, // el coche ya se ha ido
+		{FaseEsperaPlaza, FaseEsperaPlaza, false},
+	} {
+		in, err := newInspector(Inspeccion{VuelveA: tc.vuelveA}, tc.fase, func(Coche) {})
+		if tc.ok != (err == nil) || tc.ok != (in != nil) {
+			t.Errorf("tras %d vuelve a %d: %v, %v", tc.fase, tc.vuelveA, in, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Inspecciones = map[int]Inspeccion{FaseEntrega: {VuelveA: FaseMecanico}}
+	if _, err := startSimulation(time.Now(), make(chan LogEvent), cfg); err == nil {
+		t.Error("la simulación arranca con una inspección tras la entrega")
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// InventarioConfig describe los repuestos que consume la fase de mecánico
+// y cómo se reponen. Política (s, Q) por pieza: cuando el stock más lo ya
+// pedido baja a PuntoPedido, se piden Lote unidades que llegan tras
+// PlazoPedido (tiempo de simulación).
+type InventarioConfig struct {
+	Consumo map[string]map[string]int // categoría -> pieza -> unidades por reparación
+
+	Inicial     map[string]int
+	PuntoPedido map[string]int
+	Lote        map[string]int
+	PlazoPedido map[string]time.Duration
+
+	// Sin piezas, el mecánico coge otro coche cuyas piezas sí haya
+	// (false = espera con el coche a que lleguen).
+	Saltar bool
+}
+
+// DefaultInventario es un inventario pequeño para la ejecución manual:
+// se agota enseguida y deja ver las roturas de stock.
+func DefaultInventario(plazo time.Duration) InventarioConfig {
+	piezas := []string{"aceite", "filtro", "bateria", "pintura"}
+	cfg := InventarioConfig{
+		Consumo: map[string]map[string]int{
+			CatA: {"aceite": 1, "filtro": 1},
+			CatB: {"bateria": 1},
+			CatC: {"pintura": 1},
+		},
+		Inicial:     map[string]int{},
+		PuntoPedido: map[string]int{},
+		Lote:        map[string]int{},
+		PlazoPedido: map[string]time.Duration{},
+	}
+	for _, p := range piezas {
+		cfg.Inicial[p] = 2
+		cfg.PuntoPedido[p] = 1
+		cfg.Lote[p] = 3
+		cfg.PlazoPedido[p] = plazo
+	}
+	return cfg
+}
+
+// StockPieza es el estado de una pieza en un instante.
+type StockPieza struct {
+	Stock    int  `json:"stock"`
+	EnCamino int  `json:"enCamino"`
+	Agotada  bool `json:"agotada,omitempty"` // no llega para un coche (lo pidió y no había o, al saltar, no queda para otro)
+}
+
+// Inventario de repuestos. Como ResourcePool: una goroutine es la dueña del
+// stock y todo se pide por canales.
+type Inventario struct {
+	cfg    InventarioConfig
+	saltar bool
+	vida   *vida
+
+	tomar  chan tomarReq
+	cancel chan cancelTomar
+	snap   chan chan map[string]StockPieza
+}
+
+type tomarReq struct {
+	categoria string
+	esperar   bool
+	reply     chan bool
+}
+
+type cancelTomar struct {
+	reply chan bool // identifica la petición a retirar
+	done  chan struct{}
+}
+
+// pedido es un lote de una pieza que llega al almacén.
+type pedido struct {
+	pieza    string
+	unidades int
+}
+
+// newInventario arranca el inventario o devuelve nil si no hay consumo
+// configurado. alReponer se llama (sin bloquear) cada vez que llega un lote.
+func newInventario(cfg InventarioConfig, start time.Time, logs chan<- LogEvent, alReponer func(), v *vida) *Inventario {
+	if len(cfg.Consumo) == 0 {
+		return nil
+	}
+	inv := &Inventario{
+		cfg:    cfg,
+		saltar: cfg.Saltar,
+		vida:   v,
+		tomar:  make(chan tomarReq),
+		cancel: make(chan cancelTomar),
+		snap:   make(chan chan map[string]StockPieza),
+	}
+	v.lanzar(func() { inv.loop(start, logs, alReponer) })
+	return inv
+}
+
+// Alcanza dice, sobre una foto del stock (Snapshot), si hay piezas para
+// reparar un coche de la categoría. No pregunta al inventario ni marca nada
+// como agotado: se puede usar desde cualquier goroutine, también desde el
+// accept de una cola.
+func (inv *Inventario) Alcanza(foto map[string]StockPieza, categoria string) bool {
+	if inv == nil {
+		return true
+	}
+	for p, n := range inv.cfg.Consumo[categoria] {
+		if foto[p].Stock < n {
+			return false
+		}
+	}
+	return true
+}
+
+// Tomar retira las piezas de la categoría si están todas; si falta alguna
+// no retira nada y devuelve false.
+func (inv *Inventario) Tomar(categoria string) bool {
+	if inv == nil {
+		return true
+	}
+	reply := make(chan bool, 1)
+	enviar(inv.vida, inv.tomar, tomarReq{categoria: categoria, reply: reply})
+	return recibir(inv.vida, reply)
+}
+
+// TomarOrStop es como Tomar pero, si faltan piezas, espera a que lleguen.
+// Devuelve false si se cerró stop antes de conseguirlas.
+func (inv *Inventario) TomarOrStop(categoria string, stop <-chan struct{}) bool {
+	if inv == nil {
+		return true
+	}
+	reply := make(chan bool, 1)
+	enviar(inv.vida, inv.tomar, tomarReq{categoria: categoria, esperar: true, reply: reply})
+
+	select {
+	case ok := <-reply:
+		return ok
+	case <-stop:
+		done := make(chan struct{})
+		enviar(inv.vida, inv.cancel, cancelTomar{reply: reply, done: done})
+		recibir(inv.vida, done)
+		// Puede que las piezas se entregaran justo antes de cancelar.
+		select {
+		case ok := <-reply:
+			return ok
+		default:
+			return false
+		}
+	case <-inv.vida.parada():
+	}
+	runtime.Goexit()
+	return false
+}
+
+// Snapshot devuelve el stock de cada pieza.
+func (inv *Inventario) Snapshot() map[string]StockPieza {
+	if inv == nil {
+		return nil
+	}
+	reply := make(chan map[string]StockPieza, 1)
+	enviar(inv.vida, inv.snap, reply)
+	return recibir(inv.vida, reply)
+}
+
+func (inv *Inventario) loop(start time.Time, logs chan<- LogEvent, alReponer func()) {
+	stock := map[string]int{}
+	enCamino := map[string]int{}
+	agotada := map[string]bool{}
+	for _, consumo := range inv.cfg.Consumo {
+		for p := range consumo {
+			stock[p] = inv.cfg.Inicial[p]
+		}
+	}
+
+	// Peticiones de TomarOrStop esperando piezas (por orden de llegada).
+	var waiting []tomarReq
+
+	llega := make(chan pedido)
+
+	// Marca la pieza agotada (y registra la rotura la primera vez).
+	agotar := func(p string, n int) {
+		if !agotada[p] {
+			agotada[p] = true
+			logs <- LogEvent{Elapsed: simSince(start), Fase: FaseMecanico, Estado: EstadoAgotado, Pieza: p,
+				Detalle: fmt.Sprintf("sin %s (quedan %d, hacen falta %d)", p, stock[p], n)}
+		}
+	}
+
+	// Piezas que faltan para un coche de la categoría. Las que falten se
+	// marcan agotadas.
+	faltan := func(cat string) bool {
+		falta := false
+		for p, n := range inv.cfg.Consumo[cat] {
+			if stock[p] < n {
+				falta = true
+				agotar(p, n)
+			}
+		}
+		return falta
+	}
+
+	// Pide un lote de cada pieza cuya posición (stock + en camino) esté en
+	// el punto de pedido o por debajo.
+	reponer := func() {
+		piezas := make([]string, 0, len(stock))
+		for p := range stock {
+			piezas = append(piezas, p)
+		}
+		sort.Strings(piezas)
+		for _, p := range piezas {
+			lote := inv.cfg.Lote[p]
+			if lote <= 0 || stock[p]+enCamino[p] > inv.cfg.PuntoPedido[p] {
+				continue
+			}
+			enCamino[p] += lote
+			plazo := inv.cfg.PlazoPedido[p]
+			logs <- LogEvent{Elapsed: simSince(start), Fase: FaseMecanico, Estado: EstadoPedido, Pieza: p,
+				Detalle: fmt.Sprintf("pide %d de %s (stock %d, llega en %v)", lote, p, stock[p], plazo)}
+			p := pedido{pieza: p, unidades: lote}
+			inv.vida.lanzar(func() {
+				inv.vida.dormir(plazo)
+				enviar(inv.vida, llega, p)
+			})
+		}
+	}
+
+	consumir := func(cat string) {
+		for p, n := range inv.cfg.Consumo[cat] {
+			stock[p] -= n
+			// Al saltar nadie pide un coche sin piezas (se eligen con la foto
+			// del stock), así que la rotura se registra ya, al no quedar
+			// para otro coche igual.
+			if inv.saltar && stock[p] < n {
+				agotar(p, n)
+			}
+		}
+		reponer()
+	}
+
+	// Sirve a los que esperan piezas. Uno al que le falten no bloquea
+	// a los de detrás que necesitan otras.
+	flushWaiting := func() {
+		rest := waiting[:0]
+		for _, req := range waiting {
+			if faltan(req.categoria) {
+				rest = append(rest, req)
+				continue
+			}
+			consumir(req.categoria)
+			req.reply <- true
+		}
+		waiting = rest
+	}
+
+	reponer()
+
+	for {
+		select {
+		case <-inv.vida.parada():
+			return
+
+		case r := <-inv.tomar:
+			if !faltan(r.categoria) {
+				consumir(r.categoria)
+				r.reply <- true
+				continue
+			}
+			if !r.esperar {
+				r.reply <- false
+				continue
+			}
+			waiting = append(waiting, r)
+			// Quizá no se ha consumido nada y el stock ya estaba bajo mínimos.
+			reponer()
+
+		case r := <-inv.cancel:
+			for i, req := range waiting {
+				if req.reply == r.reply {
+					waiting = append(waiting[:i], waiting[i+1:]...)
+					break
+				}
+			}
+			close(r.done)
+
+		case p := <-llega:
+			stock[p.pieza] += p.unidades
+			enCamino[p.pieza] -= p.unidades
+			agotada[p.pieza] = false
+			logs <- LogEvent{Elapsed: simSince(start), Fase: FaseMecanico, Estado: EstadoRepuesto, Pieza: p.pieza,
+				Detalle: fmt.Sprintf("llegan %d de %s (stock %d)", p.unidades, p.pieza, stock[p.pieza])}
+			flushWaiting()
+			reponer()
+			if alReponer != nil {
+				alReponer()
+			}
+
+		case reply := <-inv.snap:
+			m := map[string]StockPieza{}
+			for p := range stock {
+				m[p] = StockPieza{Stock: stock[p], EnCamino: enCamino[p], Agotada: agotada[p]}
+			}
+			reply <- m
+		}
+	}
+}
@@ -1,12 +1,52 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// debugOut recibe las trazas de depuración (estado, mensajes crudos).
+// En modo dashboard se descartan para no romper la pantalla.
+var debugOut io.Writer = os.Stdout
+
+func debugln(a ...any) {
+	fmt.Fprintln(debugOut, a...)
+}
 
 // runLogger imprime logs en un único punto para evitar interleaving.
 // Formato exigido: Tiempo {t} Coche {N} Incidencia {Tipo} Fase {Fase} Estado {Entra|Sale}
-func runLogger(logs <-chan LogEvent) {
-	for ev := range logs {
-		fmt.Printf("Tiempo %v Coche %d Incidencia %s Fase %d Estado %s\n",
+// De paso acumula el Informe de la ejecución y lo entrega (en texto) a quien
+// lo pida por informes.
+func runLogger(logs <-chan LogEvent, informes <-chan chan string) {
+	inf := NewInforme()
+	for {
+		select {
+		case ev, ok := <-logs:
+			if !ok {
+				return
+			}
+			inf.Observar(ev)
+			fmt.Println(formatLogEvent(ev))
+
+		case reply := <-informes:
+			reply <- inf.String()
+		}
+	}
+}
+
+// formatLogEvent da formato a un evento. Entra/Sale siguen el formato exigido;
+// los demás eventos llevan "Evento" para distinguirlos a simple vista.
+func formatLogEvent(ev LogEvent) string {
+	switch {
+	case ev.Estado == EstadoEntra || ev.Estado == EstadoSale:
+		return fmt.Sprintf("Tiempo %v Coche %d Incidencia %s Fase %d Estado %s",
 			ev.Elapsed, ev.CocheID, ev.Incidencia, ev.Fase, ev.Estado)
+	case ev.CocheID == 0:
+		return fmt.Sprintf("Tiempo %v Taller Fase %d Evento %s %s",
+			ev.Elapsed, ev.Fase, ev.Estado, ev.Detalle)
+	default:
+		return fmt.Sprintf("Tiempo %v Coche %d Incidencia %s Fase %d Evento %s %s",
+			ev.Elapsed, ev.CocheID, ev.Incidencia, ev.Fase, ev.Estado, ev.Detalle)
 	}
 }
@@ -65,14 +65,65 @@ func categoriaDurConVariacion(cat string) time.Duration {
 }
 
 type Coche struct {
-	ID        int
-	Categoria string
+	ID        int    `json:"id"`
+	Categoria string `json:"categoria"`
+
+	Retrabajos int `json:"retrabajos,omitempty"` // veces que no ha pasado una inspección
+
+	// Urgente: sale de las colas antes que nadie y, en modo expropiativo,
+	// puede quitarle el sitio a un coche que no lo sea.
+	Urgente bool `json:"urgente,omitempty"`
+
+	// Trabajo que le quedaba en la fase cuando lo expropiaron (0 = ninguno).
+	Resto time.Duration `json:"resto,omitempty"`
+
+	// Ya tiene retiradas las piezas de la reparación (fase 1): si vuelve a la
+	// cola a mitad, no las gasta otra vez.
+	Piezas bool `json:"piezas,omitempty"`
+
+	// Coste acumulado de las fases por las que ha pasado (con Config.Costes).
+	Coste float64 `json:"coste,omitempty"`
+
+	// Entrega prometida en tiempo de simulación desde el arranque (0 = sin plazo).
+	Plazo time.Duration `json:"plazo,omitempty"`
 }
 
+// Estados de LogEvent. Entra/Sale son los del enunciado; el resto son
+// eventos propios del taller y se imprimen con otro formato.
+const (
+	EstadoEntra      = "Entra"
+	EstadoSale       = "Sale"
+	EstadoEscalado   = "Escalado"   // el autoescalado cambia los workers de una fase
+	EstadoTurno      = "Turno"      // un worker empieza o termina su turno
+	EstadoAveria     = "Averia"     // un worker se avería (pausa o aborta el coche)
+	EstadoReparada   = "Reparada"   // el worker vuelve a estar operativo
+	EstadoRetrabajo  = "Retrabajo"  // el coche no pasa la inspección y vuelve atrás
+	EstadoReserva    = "Reserva"    // se acepta una cita
+	EstadoSobreventa = "Sobreventa" // se acepta una cita por encima de la capacidad nominal
+	EstadoRechazo    = "Rechazo"    // se rechaza una cita por falta de capacidad
+	EstadoCita       = "Cita"       // llega el coche de una cita
+	EstadoAgotado    = "Agotado"    // un coche necesita una pieza y no hay stock
+	EstadoPedido     = "Pedido"     // se pide un lote de una pieza
+	EstadoRepuesto   = "Repuesto"   // llega un lote de una pieza
+	EstadoSinPiezas  = "SinPiezas"  // un mecánico espera piezas con el coche
+	EstadoPiezas     = "Piezas"     // el mecánico consigue las piezas que esperaba
+	EstadoOcioso     = "Ocioso"     // coste de las unidades de un recurso sin usar
+	EstadoExpropiado = "Expropiado" // el coche deja el servicio por uno urgente o por SOLO X
+	EstadoReanuda    = "Reanuda"    // un coche parado o expropiado vuelve al servicio con su trabajo pendiente
+	EstadoPausa      = "Pausa"      // el estado para al coche en servicio (política pausar)
+	EstadoAbortado   = "Abortado"   // el estado saca al coche del servicio (política abortar)
+)
+
 type LogEvent struct {
 	Elapsed    time.Duration
-	CocheID    int
+	CocheID    int // 0 en eventos del taller que no son de un coche
 	Incidencia string
 	Fase       int
-	Estado     string // "Entra" o "Sale"
+	Estado     string        // EstadoEntra, EstadoSale u otro evento
+	Detalle    string        // texto libre para los eventos que no son Entra/Sale
+	Plazo      time.Duration // entrega prometida del coche (en los Sale), 0 = sin plazo
+	Pieza      string        // repuesto de los eventos de inventario
+	Espera     time.Duration // lo que ha esperado el coche por las piezas (EstadoPiezas)
+	Coste      float64       // coste de la fase (Sale), de lo trabajado en un servicio a medias o del recurso parado (Ocioso)
+	Importe    float64       // lo que se cobra por el coche (Sale de la entrega)
 }
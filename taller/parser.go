@@ -3,11 +3,14 @@ package main
 import (
 	"strconv"
 	"strings"
+
+	"sistemasdistribuidos-p4/internal/sobres"
 )
 
 // parseIncoming recibe trozos (pueden venir fragmentados) y reconstruye por '\n'.
-// Extrae solo enteros 0..9; el resto (textos del servidor) se ignora.
-func parseIncoming(incoming <-chan string, out chan<- int) {
+// Extrae solo enteros 0..maxCodigo y sobres (JSON) con un código en ese rango;
+// el resto (textos del servidor, los Ack de otros talleres...) se ignora.
+func parseIncoming(incoming <-chan string, out chan<- Codigo) {
 	pending := ""
 
 	for chunk := range incoming {
@@ -26,16 +29,23 @@ func parseIncoming(incoming <-chan string, out chan<- int) {
 				continue
 			}
 
+			if s, ok := sobres.Abrir(line); ok {
+				if s.Codigo >= 0 && s.Codigo <= maxCodigo {
+					out <- Codigo{N: s.Codigo, Sobre: &s}
+				}
+				continue
+			}
+
 			// Recomendación del profe: strconv.Atoi.
 			n, err := strconv.Atoi(line)
 			if err != nil {
 				continue
 			}
-			if n < 0 || n > 9 {
+			if n < 0 || n > maxCodigo {
 				continue
 			}
 
-			out <- n
+			out <- Codigo{N: n}
 		}
 	}
 
@@ -1,189 +1,451 @@
 package main
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // stateProvider permite sustituir el origen del estado en tests.
 // Por defecto apunta a getState (controlador real).
 var stateProvider = func() TallerState { return getState() }
 
-// sleepFn permite acelerar tests si hiciera falta en el futuro.
-var sleepFn = time.Sleep
+// sleepFn da el aviso de que ha pasado un tiempo de simulación (vida.dormir
+// espera a él o a que se pare la simulación); los tests lo aceleran.
+var sleepFn = time.After
 
-// fase0Plaza: respeta estado (inactivo/cerrado/solo categoría), usa plazas y al salir ENCOLA en fase 1.
-func fase0Plaza(start time.Time, c Coche, plazas chan struct{}, q1 *PhaseQueue, logs chan<- LogEvent) {
-	for {
-		st := stateProvider()
+// simSince mide tiempo de simulación (el "Tiempo" de los logs); los tests lo
+// escalan igual que sleepFn para que esperas, plazos y cooldowns guarden
+// proporción con los servicios.
+var simSince = time.Since
 
-		// Si cerrado o inactivo: espera y reintenta.
-		if st.Cerrado || !st.Activo {
-			sleepFn(200 * time.Millisecond)
-			continue
-		}
+// puedeAtender indica si el estado remoto y el horario de apertura permiten
+// atender el coche (inactivo/cerrado/fuera de horario/solo categoría).
+func puedeAtender(st TallerState, cal Calendario, c Coche) bool {
+	if st.Cerrado || !st.Activo || !cal.Abierto() {
+		return false
+	}
+	if st.SoloCategoria != "" && st.SoloCategoria != c.Categoria {
+		return false
+	}
+	return true
+}
+
+// Qué hace una fase con el coche que tiene en servicio si el estado pasa a
+// cerrado, inactivo o SOLO de otra categoría (Config.EnServicio).
+const (
+	EnServicioTerminar = "terminar" // lo acaba (por defecto): el estado solo frena al siguiente
+	EnServicioPausar   = "pausar"   // se para con el recurso y sigue donde iba al reabrir
+	EnServicioAbortar  = "abortar"  // suelta el recurso y el coche vuelve a su cola desde cero
+)
+
+// estadoPermite es puedeAtender sin el horario: el cierre por horario deja
+// acabar el coche en curso, el del estado no tiene por qué.
+func estadoPermite(st TallerState, c Coche) bool {
+	return puedeAtender(st, Calendario{}, c)
+}
+
+// detener aplica la política en servicio si el estado ya no permite seguir
+// con el coche. Con pausar bloquea hasta que se pueda seguir (la pausa se
+// cobra con el resto del paso, en el Sale); devuelve false si hay que
+// abortar, tras cobrar con parcial lo trabajado (el que llama suelta el
+// recurso y devuelve el coche).
+func detener(v *vida, start time.Time, politica string, fase int, quien string, car Coche, resto time.Duration, parcial func() float64, logs chan<- LogEvent) bool {
+	if politica == "" || politica == EnServicioTerminar || estadoPermite(stateProvider(), car) {
+		return true
+	}
+	inc := categoriaTipo(car.Categoria)
+	motivo := stateSummary(stateProvider())
+
+	if politica == EnServicioAbortar {
+		logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: fase, Estado: EstadoAbortado, Coste: parcial(),
+			Detalle: fmt.Sprintf("%s lo deja por %s (faltaban %v)", quien, motivo, resto.Truncate(time.Millisecond))}
+		return false
+	}
+
+	logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: fase, Estado: EstadoPausa,
+		Detalle: fmt.Sprintf("%s para por %s (faltan %v)", quien, motivo, resto.Truncate(time.Millisecond))}
+	for !estadoPermite(stateProvider(), car) {
+		v.dormir(200 * time.Millisecond)
+	}
+	logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: fase, Estado: EstadoReanuda,
+		Detalle: fmt.Sprintf("%s sigue (faltan %v)", quien, resto.Truncate(time.Millisecond))}
+	return true
+}
+
+// plazaSpec es lo que necesita la fase 0 (igual para todos los coches).
+type plazaSpec struct {
+	cal        Calendario
+	costes     CostesConfig
+	enServicio string // política en servicio de la fase 0
+
+	plazas *ResourcePool
+	q1     *PhaseQueue
 
-		// Restricción “solo categoría X”.
-		if st.SoloCategoria != "" && st.SoloCategoria != c.Categoria {
-			sleepFn(200 * time.Millisecond)
+	reg *Registro // nil = sin checkpoints
+
+	vida *vida
+}
+
+// fase0Plaza: respeta estado (inactivo/cerrado/solo categoría) y horario, usa plazas y al salir ENCOLA en fase 1.
+// El tiempo en la plaza se suma al coste del coche. Si el estado cambia con el
+// coche ya en la plaza, se aplica la política en servicio de la fase 0.
+// Un coche restaurado de un checkpoint a mitad de plaza solo hace su Resto.
+func fase0Plaza(start time.Time, p plazaSpec, c Coche, logs chan<- LogEvent) {
+	cal, plazas := p.cal, p.plazas
+	p.reg.EnCola(c, FaseEsperaPlaza)
+	walLog.anotarCoche(WALAlta, FaseEsperaPlaza, c)
+	for {
+		// Si cerrado, inactivo, fuera de horario o "solo categoría X": espera y reintenta.
+		if !puedeAtender(stateProvider(), cal, c) {
+			p.vida.dormir(200 * time.Millisecond)
 			continue
 		}
 
 		// Coge plaza (bloquea si no hay).
-		plazas <- struct{}{}
+		plazas.Acquire()
 
 		// Re-chequeo por si cambió justo después.
-		st2 := stateProvider()
-		if st2.Cerrado || !st2.Activo || (st2.SoloCategoria != "" && st2.SoloCategoria != c.Categoria) {
-			<-plazas
-			sleepFn(200 * time.Millisecond)
+		if !puedeAtender(stateProvider(), cal, c) {
+			plazas.Release()
+			p.vida.dormir(200 * time.Millisecond)
 			continue
 		}
 
 		inc := categoriaTipo(c.Categoria)
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: c.ID, Incidencia: inc, Fase: FaseEsperaPlaza, Estado: "Entra"}
+		entra := simSince(start)
+		logs <- LogEvent{Elapsed: entra, CocheID: c.ID, Incidencia: inc, Fase: FaseEsperaPlaza, Estado: EstadoEntra}
+		walLog.anotarCoche(WALEntra, FaseEsperaPlaza, c)
+
+		dur := categoriaDurConVariacion(c.Categoria)
+		if c.Resto > 0 {
+			dur, c.Resto = c.Resto, 0
+		}
 
-		sleepFn(categoriaDurConVariacion(c.Categoria))
+		// A trozos, para poder parar o abortar si cambia el estado.
+		parcial := func() float64 { return p.costes.cobrarParcial(&c, FaseEsperaPlaza, simSince(start)-entra) }
+		abortado := false
+		for resto := dur; resto > 0; {
+			p.reg.EnServicio(c, FaseEsperaPlaza, resto)
+			if !detener(p.vida, start, p.enServicio, FaseEsperaPlaza, "plaza", c, resto, parcial, logs) {
+				abortado = true
+				break
+			}
+			paso := min(servicioTick, resto)
+			p.vida.dormir(paso)
+			resto -= paso
+		}
+		if abortado {
+			p.reg.EnCola(c, FaseEsperaPlaza)
+			walLog.anotarCoche(WALAlta, FaseEsperaPlaza, c)
+			plazas.Release()
+			p.vida.dormir(200 * time.Millisecond)
+			continue
+		}
 
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: c.ID, Incidencia: inc, Fase: FaseEsperaPlaza, Estado: "Sale"}
+		sale := simSince(start)
+		coste, _ := p.costes.cobrar(&c, FaseEsperaPlaza, sale-entra)
+		logs <- LogEvent{Elapsed: sale, CocheID: c.ID, Incidencia: inc, Fase: FaseEsperaPlaza, Estado: EstadoSale, Plazo: c.Plazo, Coste: coste}
+		walLog.anotarCoche(WALSale, FaseEsperaPlaza, c)
 
-		<-plazas
+		plazas.Release()
 
 		// Encolamos en la Fase 1 con prioridad.
-		q1.Enqueue(c)
+		p.reg.EnCola(c, FaseMecanico)
+		p.q1.Enqueue(c)
 		return
 	}
 }
 
-// phase1Worker: worker de mecánico.
-// - Saca coches de la cola q1 aplicando PRIORIDAD del estado.
-// - Respeta inactivo/cerrado/solo categoría antes de empezar un trabajo.
-// - Usa "mecanicos" como semáforo de recurso físico.
-// - Al terminar, encola en Fase 2.
-func phase1Worker(start time.Time, q1 *PhaseQueue, q2 *PhaseQueue, mecanicos chan struct{}, logs chan<- LogEvent) {
-	for {
-		st := stateProvider()
-		car := q1.Dequeue(st)
-
-		// Espera a que el estado permita atender.
-		for {
-			st2 := stateProvider()
-			if st2.Cerrado || !st2.Activo {
-				sleepFn(200 * time.Millisecond)
-				continue
-			}
-			if st2.SoloCategoria != "" && st2.SoloCategoria != car.Categoria {
-				sleepFn(200 * time.Millisecond)
-				continue
-			}
-			break
-		}
+// workerSpec describe un worker concreto de las fases 1..3.
+type workerSpec struct {
+	fase    int
+	recurso string
+	n       int // nº de worker dentro de su fase (1, 2, ...)
 
-		// Espera mecánico libre.
-		mecanicos <- struct{}{}
+	in  *PhaseQueue
+	out *PhaseQueue // nil en la última fase
+	res *ResourcePool
 
-		// Re-chequeo antes del trabajo real.
-		st3 := stateProvider()
-		if st3.Cerrado || !st3.Activo || (st3.SoloCategoria != "" && st3.SoloCategoria != car.Categoria) {
-			<-mecanicos
-			// Devolvemos el coche a la cola para no perderlo.
-			q1.Enqueue(car)
-			sleepFn(200 * time.Millisecond)
-			continue
-		}
+	perfil Perfil // nil = atiende todas las categorías a tiempo normal
 
-		inc := categoriaTipo(car.Categoria)
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: car.ID, Incidencia: inc, Fase: FaseMecanico, Estado: "Entra"}
+	cal   Calendario
+	turno Turno // nil = siempre disponible
 
-		sleepFn(categoriaDurConVariacion(car.Categoria))
+	averia *averiaWorker // nil = no se avería
 
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: car.ID, Incidencia: inc, Fase: FaseMecanico, Estado: "Sale"}
+	inspector *inspector // nil = sin inspección al terminar la fase
 
-		<-mecanicos
+	inventario *Inventario // nil = no gasta piezas (solo lo tiene la fase 1)
 
-		// Pasa a Fase 2
-		q2.Enqueue(car)
-	}
+	costes CostesConfig
+
+	// Modo expropiativo: deja el coche a medias (guardando lo que le falta)
+	// si llega uno urgente o el estado pasa a SOLO de otra categoría.
+	expropiativo bool
+
+	enServicio string // EnServicio*: qué hacer con el coche si cambia el estado ("" = terminar)
+
+	reg *Registro // nil = sin checkpoints
+
+	stop <-chan struct{} // al cerrarse, el worker se retira
+	vida *vida
 }
 
-// phase2Worker: limpieza.
-// Mismo patrón que fase 1, con su propio recurso y cola.
-func phase2Worker(start time.Time, q2 *PhaseQueue, q3 *PhaseQueue, limpieza chan struct{}, logs chan<- LogEvent) {
+// phaseWorker: worker de las fases 1 (mecánico), 2 (limpieza) y 3 (entrega).
+// - Saca de la cola in los coches que sabe atender, aplicando PRIORIDAD del estado.
+// - Respeta inactivo/cerrado/solo categoría antes de empezar un trabajo.
+// - Usa res como recurso físico limitado.
+// - El tiempo de servicio se multiplica según su perfil, y puede averiarse a mitad.
+// - En la fase 1 gasta piezas: sin stock espera con el coche (o salta a otro coche).
+// - Si el estado cambia a mitad de un coche, lo acaba, se para o lo aborta según enServicio.
+// - En modo expropiativo deja el coche por uno urgente o por SOLO X, y lo retoma donde iba.
+// - Al terminar, pasa la inspección (si la hay) y encola en out (nil en la última fase).
+// - Fuera de su turno no coge coches (el que tenga entre manos lo termina).
+// - Cuando se cierra stop termina, pero nunca a mitad de un coche.
+func phaseWorker(start time.Time, w workerSpec, logs chan<- LogEvent) {
+	enTurno := w.cal.Disponible(w.turno)
+
 	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		// Fuera de turno (o en el descanso): no coge coches.
+		if disponible := w.cal.Disponible(w.turno); disponible != enTurno {
+			enTurno = disponible
+			detalle := fmt.Sprintf("%s %d termina turno a las %s", w.recurso, w.n, formatHora(w.cal.Hora()))
+			if enTurno {
+				detalle = fmt.Sprintf("%s %d empieza turno a las %s", w.recurso, w.n, formatHora(w.cal.Hora()))
+			}
+			logs <- LogEvent{Elapsed: simSince(start), Fase: w.fase, Estado: EstadoTurno, Detalle: detalle}
+		}
+		if !enTurno {
+			w.vida.dormir(200 * time.Millisecond)
+			continue
+		}
+
 		st := stateProvider()
-		car := q2.Dequeue(st)
+		car, ok := w.in.DequeueRenovando(st, w.aceptar, w.stop)
+		if !ok {
+			return
+		}
+
+		// Si le ha pillado el fin de turno esperando coche, lo devuelve
+		// a su sitio (al principio: ya estaba admitido, no espera hueco).
+		if !w.cal.Disponible(w.turno) {
+			w.in.EnqueueFront(car)
+			continue
+		}
 
-		for {
-			st2 := stateProvider()
-			if st2.Cerrado || !st2.Activo {
-				sleepFn(200 * time.Millisecond)
-				continue
+		// Espera a que el estado y el horario permitan atender. En modo
+		// expropiativo no se queda bloqueado con un coche que SOLO X excluye.
+		devuelto := false
+		for !puedeAtender(stateProvider(), w.cal, car) {
+			if st := stateProvider(); w.expropiativo && st.SoloCategoria != "" && st.SoloCategoria != car.Categoria {
+				w.in.EnqueueFront(car)
+				devuelto = true
+				break
 			}
-			if st2.SoloCategoria != "" && st2.SoloCategoria != car.Categoria {
-				sleepFn(200 * time.Millisecond)
-				continue
+			w.vida.dormir(200 * time.Millisecond)
+		}
+		if devuelto {
+			continue
+		}
+
+		// Piezas de la reparación, antes de ocupar al mecánico.
+		if w.inventario != nil && !car.Piezas {
+			if !w.piezas(start, car, logs) {
+				// Retirado esperando piezas, o (al saltar) otro se llevó las últimas.
+				w.in.EnqueueFront(car)
+				if w.inventario.saltar {
+					continue
+				}
+				return
 			}
-			break
+			car.Piezas = true
 		}
 
-		limpieza <- struct{}{}
+		// Espera hueco libre en el recurso. Si retiran al worker mientras
+		// espera (p.ej. el recurso bajó a 0), devuelve el coche a la cola.
+		if !w.res.AcquireOrStop(w.stop) {
+			w.in.EnqueueFront(car)
+			return
+		}
 
-		st3 := stateProvider()
-		if st3.Cerrado || !st3.Activo || (st3.SoloCategoria != "" && st3.SoloCategoria != car.Categoria) {
-			<-limpieza
-			q2.Enqueue(car)
-			sleepFn(200 * time.Millisecond)
+		// Re-chequeo antes del trabajo real.
+		if !puedeAtender(stateProvider(), w.cal, car) {
+			w.res.Release()
+			// Devolvemos el coche a la cola (a su sitio) para no perderlo.
+			w.in.EnqueueFront(car)
+			w.vida.dormir(200 * time.Millisecond)
 			continue
 		}
 
 		inc := categoriaTipo(car.Categoria)
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: car.ID, Incidencia: inc, Fase: FaseLimpieza, Estado: "Entra"}
+		entra := simSince(start)
+		logs <- LogEvent{Elapsed: entra, CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoEntra}
+		walLog.anotarCoche(WALEntra, w.fase, car)
+
+		// Un coche expropiado solo hace el trabajo que le faltaba.
+		dur := w.perfil.Duracion(car.Categoria, categoriaDurConVariacion(car.Categoria))
+		if car.Resto > 0 {
+			dur, car.Resto = car.Resto, 0
+			logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoReanuda,
+				Detalle: fmt.Sprintf("%s %d lo retoma (faltan %v)", w.recurso, w.n, dur.Truncate(time.Millisecond))}
+		}
+
+		if !servir(start, w, car, entra, dur, logs) {
+			continue
+		}
 
-		sleepFn(categoriaDurConVariacion(car.Categoria))
+		// Entregado antes del Sale: un checkpoint no puede tener un coche
+		// que ya ha salido del taller (se entregaría dos veces).
+		if w.out == nil {
+			w.reg.Entregado(car.ID)
+		}
+
+		// Se cobra el tiempo real en la fase (pausas por avería incluidas).
+		sale := simSince(start)
+		coste, importe := w.costes.cobrar(&car, w.fase, sale-entra)
+		logs <- LogEvent{Elapsed: sale, CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoSale, Plazo: car.Plazo, Coste: coste, Importe: importe}
+		walLog.anotarCoche(WALSale, w.fase, car)
+
+		w.res.Release()
 
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: car.ID, Incidencia: inc, Fase: FaseLimpieza, Estado: "Sale"}
+		// Las piezas ya se han gastado; un retrabajo en fase 1 pide otras.
+		car.Piezas = false
 
-		<-limpieza
+		// Inspección de calidad: si no pasa, vuelve atrás (sin esperar hueco).
+		if !w.inspector.revisar(start, w.fase, &car, logs) {
+			w.reg.EnCola(car, w.inspector.VuelveA)
+			w.inspector.devolver(car)
+			continue
+		}
 
-		// Pasa a Fase 3
-		q3.Enqueue(car)
+		// Pasa a la siguiente fase (o se entrega).
+		if w.out != nil {
+			w.reg.EnCola(car, w.fase+1)
+			w.out.Enqueue(car)
+		}
 	}
 }
 
-// phase3Worker: entrega.
-// Última fase del pipeline.
-func phase3Worker(start time.Time, q3 *PhaseQueue, entrega chan struct{}, logs chan<- LogEvent) {
-	for {
-		st := stateProvider()
-		car := q3.Dequeue(st)
+// aceptar dice qué coches puede coger ahora el worker: los de su perfil y,
+// si salta coches sin piezas, los que tienen piezas según una foto del stock
+// tomada aquí (la cola evalúa el accept sin preguntar al inventario; cuando
+// llegan piezas, Reintentar hace que se pida otro).
+func (w workerSpec) aceptar() func(Coche) bool {
+	if w.inventario == nil || !w.inventario.saltar {
+		return w.perfil.Puede
+	}
+	foto := w.inventario.Snapshot()
+	return func(c Coche) bool {
+		return w.perfil.Puede(c) && (c.Piezas || w.inventario.Alcanza(foto, c.Categoria))
+	}
+}
 
-		for {
-			st2 := stateProvider()
-			if st2.Cerrado || !st2.Activo {
-				sleepFn(200 * time.Millisecond)
-				continue
-			}
-			if st2.SoloCategoria != "" && st2.SoloCategoria != car.Categoria {
-				sleepFn(200 * time.Millisecond)
-				continue
-			}
-			break
-		}
+// piezas retira las piezas del coche del inventario. Al saltar no espera:
+// el coche se eligió porque había piezas. Si no, espera a que lleguen y
+// registra cuánto ha esperado; devuelve false si retiran al worker antes.
+func (w workerSpec) piezas(start time.Time, car Coche, logs chan<- LogEvent) bool {
+	if w.inventario.Tomar(car.Categoria) {
+		return true
+	}
+	if w.inventario.saltar {
+		return false
+	}
 
-		entrega <- struct{}{}
+	inc := categoriaTipo(car.Categoria)
+	desde := simSince(start)
+	logs <- LogEvent{Elapsed: desde, CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoSinPiezas,
+		Detalle: fmt.Sprintf("%s %d espera piezas", w.recurso, w.n)}
+	if !w.inventario.TomarOrStop(car.Categoria, w.stop) {
+		return false
+	}
+	ahora := simSince(start)
+	logs <- LogEvent{Elapsed: ahora, CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoPiezas, Espera: ahora - desde,
+		Detalle: fmt.Sprintf("%s %d consigue las piezas tras %v", w.recurso, w.n, (ahora - desde).Truncate(time.Millisecond))}
+	return true
+}
 
-		st3 := stateProvider()
-		if st3.Cerrado || !st3.Activo || (st3.SoloCategoria != "" && st3.SoloCategoria != car.Categoria) {
-			<-entrega
-			q3.Enqueue(car)
-			sleepFn(200 * time.Millisecond)
-			continue
+// expropiar dice por qué hay que dejar el coche en servicio ("" = seguir):
+// el estado pasa a SOLO de otra categoría, o espera un coche urgente que
+// este worker podría atender (solo si el actual no lo es).
+func (w workerSpec) expropiar(car Coche) string {
+	if !w.expropiativo {
+		return ""
+	}
+	st := stateProvider()
+	if st.SoloCategoria != "" && st.SoloCategoria != car.Categoria {
+		return "SOLO " + st.SoloCategoria
+	}
+	if !car.Urgente && w.in.Reclamar(st, w.aceptar()) {
+		return "un coche urgente"
+	}
+	return ""
+}
+
+// Cada cuánto (tiempo de simulación) comprueba el worker si le ha pasado
+// algo a mitad de un coche.
+const servicioTick = 100 * time.Millisecond
+
+// servir simula el trabajo sobre el coche a trozos para poder reaccionar a
+// averías, expropiaciones y cambios de estado. Devuelve false si el servicio se abortó o se
+// expropió: en ese caso el recurso ya se ha liberado y el coche ha vuelto
+// al principio de la cola, con lo trabajado desde entra ya cobrado.
+func servir(start time.Time, w workerSpec, car Coche, entra, dur time.Duration, logs chan<- LogEvent) bool {
+	inc := categoriaTipo(car.Categoria)
+	parcial := func() float64 { return w.costes.cobrarParcial(&car, w.fase, simSince(start)-entra) }
+
+	for resto := dur; resto > 0; {
+		w.reg.EnServicio(car, w.fase, resto)
+
+		if motivo := w.expropiar(car); motivo != "" {
+			logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoExpropiado, Coste: parcial(),
+				Detalle: fmt.Sprintf("%s %d lo deja por %s (faltan %v)", w.recurso, w.n, motivo, resto.Truncate(time.Millisecond))}
+			car.Resto = resto
+			w.reg.EnCola(car, w.fase)
+			w.res.Release()
+			w.in.EnqueueFront(car)
+			return false
 		}
 
-		inc := categoriaTipo(car.Categoria)
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: car.ID, Incidencia: inc, Fase: FaseEntrega, Estado: "Entra"}
+		// Cerrado/inactivo/SOLO de otra categoría con el coche a medias.
+		if !detener(w.vida, start, w.enServicio, w.fase, fmt.Sprintf("%s %d", w.recurso, w.n), car, resto, parcial, logs) {
+			w.reg.EnCola(car, w.fase)
+			w.res.Release()
+			w.in.EnqueueFront(car)
+			return false
+		}
 
-		sleepFn(categoriaDurConVariacion(car.Categoria))
+		paso, averia := w.averia.paso(min(servicioTick, resto))
+		w.vida.dormir(paso)
+		resto -= paso
+		if !averia {
+			continue
+		}
 
-		logs <- LogEvent{Elapsed: time.Since(start), CocheID: car.ID, Incidencia: inc, Fase: FaseEntrega, Estado: "Sale"}
+		reparacion := w.averia.reparar()
+		if w.averia.abortar {
+			logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoAveria, Coste: parcial(),
+				Detalle: fmt.Sprintf("%s %d aborta (faltaban %v, reparación %v)", w.recurso, w.n, resto.Truncate(time.Millisecond), reparacion.Truncate(time.Millisecond))}
+			w.reg.EnCola(car, w.fase)
+			w.res.Release()
+			w.in.EnqueueFront(car)
+
+			w.vida.dormir(reparacion)
+			logs <- LogEvent{Elapsed: simSince(start), Fase: w.fase, Estado: EstadoReparada, Detalle: fmt.Sprintf("%s %d", w.recurso, w.n)}
+			return false
+		}
 
-		<-entrega
+		// Pausa: el coche (y el recurso) esperan a la reparación, que se
+		// cobra con el resto del paso en el Sale.
+		logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoAveria,
+			Detalle: fmt.Sprintf("%s %d pausa (faltan %v, reparación %v)", w.recurso, w.n, resto.Truncate(time.Millisecond), reparacion.Truncate(time.Millisecond))}
+		w.vida.dormir(reparacion)
+		logs <- LogEvent{Elapsed: simSince(start), CocheID: car.ID, Incidencia: inc, Fase: w.fase, Estado: EstadoReparada, Detalle: fmt.Sprintf("%s %d", w.recurso, w.n)}
 	}
+	return true
 }
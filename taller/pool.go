@@ -0,0 +1,176 @@
+package main
+
+import "runtime"
+
+// ResourcePool es un recurso físico (plazas, mecánicos, limpieza, entrega)
+// cuya capacidad se puede cambiar con la simulación en marcha.
+// Igual que PhaseQueue: una goroutine es la dueña de los datos y todo
+// se pide por canales (sin mutex).
+type ResourcePool struct {
+	vida *vida // nil = no se para
+
+	acquire chan chan struct{}
+	release chan struct{}
+	cancel  chan cancelAcq
+	resize  chan int
+	fits    chan chan struct{}
+	snap    chan chan PoolSnapshot
+}
+
+// PoolSnapshot es una foto del recurso en un instante.
+type PoolSnapshot struct {
+	Capacidad int `json:"capacidad"`
+	Ocupados  int `json:"ocupados"`
+	Esperando int `json:"esperando"`
+}
+
+type cancelAcq struct {
+	grant chan struct{} // identifica la petición de Acquire a retirar
+	done  chan struct{}
+}
+
+// NewResourcePool crea el recurso con la capacidad inicial y arranca su goroutine.
+func NewResourcePool(capacity int) *ResourcePool {
+	return newResourcePool(capacity, nil)
+}
+
+// newResourcePool es NewResourcePool dentro de la vida de una simulación.
+func newResourcePool(capacity int, v *vida) *ResourcePool {
+	p := &ResourcePool{
+		vida:    v,
+		acquire: make(chan chan struct{}),
+		release: make(chan struct{}),
+		cancel:  make(chan cancelAcq),
+		resize:  make(chan int),
+		fits:    make(chan chan struct{}),
+		snap:    make(chan chan PoolSnapshot),
+	}
+	v.lanzar(func() { p.loop(capacity) })
+	return p
+}
+
+// Acquire bloquea hasta conseguir un hueco libre (orden de llegada).
+func (p *ResourcePool) Acquire() {
+	grant := make(chan struct{})
+	enviar(p.vida, p.acquire, grant)
+	recibir(p.vida, grant)
+}
+
+// AcquireOrStop es como Acquire pero deja de esperar si se cierra stop.
+// Devuelve false si se paró sin conseguir hueco.
+func (p *ResourcePool) AcquireOrStop(stop <-chan struct{}) bool {
+	grant := make(chan struct{})
+	enviar(p.vida, p.acquire, grant)
+
+	select {
+	case <-grant:
+		return true
+	case <-stop:
+		done := make(chan struct{})
+		enviar(p.vida, p.cancel, cancelAcq{grant: grant, done: done})
+		recibir(p.vida, done)
+		// Puede que el hueco se concediera justo antes de cancelar.
+		select {
+		case <-grant:
+			return true
+		default:
+			return false
+		}
+	case <-p.vida.parada():
+	}
+	runtime.Goexit()
+	return false
+}
+
+// Release devuelve un hueco al recurso.
+func (p *ResourcePool) Release() {
+	enviar(p.vida, p.release, struct{}{})
+}
+
+// SetCapacity cambia la capacidad del recurso.
+// Si baja por debajo de los ocupados no se echa a nadie: simplemente no se
+// concede ningún hueco nuevo hasta que se liberen los sobrantes.
+func (p *ResourcePool) SetCapacity(n int) {
+	if n < 0 {
+		n = 0
+	}
+	enviar(p.vida, p.resize, n)
+}
+
+// WaitFits bloquea hasta que los ocupados no superan la capacidad
+// (útil tras encoger el recurso con trabajos en marcha).
+func (p *ResourcePool) WaitFits() {
+	done := make(chan struct{})
+	enviar(p.vida, p.fits, done)
+	recibir(p.vida, done)
+}
+
+// Snapshot devuelve capacidad, ocupados y peticiones en espera.
+func (p *ResourcePool) Snapshot() PoolSnapshot {
+	reply := make(chan PoolSnapshot, 1)
+	enviar(p.vida, p.snap, reply)
+	return recibir(p.vida, reply)
+}
+
+func (p *ResourcePool) loop(capacity int) {
+	inUse := 0
+
+	// Peticiones de Acquire esperando hueco (FIFO).
+	var waiting []chan struct{}
+
+	// Peticiones de WaitFits hasta que inUse <= capacity.
+	var fitWaiters []chan struct{}
+
+	// Concede huecos mientras haya capacidad libre y avisa a WaitFits.
+	grant := func() {
+		for len(waiting) > 0 && inUse < capacity {
+			g := waiting[0]
+			waiting = waiting[1:]
+			inUse++
+			close(g)
+		}
+		if inUse <= capacity {
+			for _, f := range fitWaiters {
+				close(f)
+			}
+			fitWaiters = nil
+		}
+	}
+
+	for {
+		select {
+		case <-p.vida.parada():
+			return
+
+		case g := <-p.acquire:
+			waiting = append(waiting, g)
+			grant()
+
+		case <-p.release:
+			if inUse > 0 {
+				inUse--
+			}
+			grant()
+
+		case r := <-p.cancel:
+			for i, g := range waiting {
+				if g == r.grant {
+					waiting = append(waiting[:i], waiting[i+1:]...)
+					break
+				}
+			}
+			close(r.done)
+
+		case n := <-p.resize:
+			capacity = n
+			grant()
+
+		case f := <-p.fits:
+			fitWaiters = append(fitWaiters, f)
+			grant()
+
+		case reply := <-p.snap:
+			reply <- PoolSnapshot{Capacidad: capacity, Ocupados: inUse, Esperando: len(waiting)}
+		}
+	}
+}
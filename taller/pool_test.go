@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Al encoger un recurso con trabajos en marcha no se echa a nadie, pero no se
+// concede ningún hueco nuevo hasta que los ocupados caben en la capacidad.
+func TestResourcePool_EncogerEsperaAOcupados(t *testing.T) {
+	p := NewResourcePool(3)
+	p.Acquire()
+	p.Acquire()
+	p.Acquire()
+
+	p.SetCapacity(1)
+
+	got := make(chan struct{})
+	go func() {
+		p.Acquire()
+		close(got)
+	}()
+
+	fits := make(chan struct{})
+	go func() {
+		p.WaitFits()
+		close(fits)
+	}()
+
+	p.Release()
+	p.Release()
+	select {
+	case <-got:
+		t.Fatal("se concedió hueco con los ocupados aún en la capacidad")
+	case <-fits:
+	case <-time.After(time.Second):
+		t.Fatal("WaitFits no terminó con ocupados == capacidad")
+	}
+
+	p.Release()
+	select {
+	case <-got:
+	case <-time.After(time.Second):
+		t.Fatal("no se concedió el hueco tras liberar")
+	}
+
+	if snap := p.Snapshot(); snap.Capacidad != 1 || snap.Ocupados != 1 {
+		t.Fatalf("snapshot inesperado: %+v", snap)
+	}
+}
@@ -1,49 +1,206 @@
 package main
 
+import (
+	"runtime"
+	"time"
+)
+
 // PhaseQueue es una cola con prioridad y capacidad máxima.
 // Implementación estilo "actor": una goroutine es la dueña de los datos.
 // No usamos mutex, solo canales.
 type PhaseQueue struct {
 	capacity int
 
-	enq chan enqReq
-	deq chan deqReq
+	// edf: dentro de lo que permite el estado, sale primero el coche con la
+	// entrega prometida más cercana (Earliest Deadline First) en vez de A->B->C.
+	edf bool
+
+	// fase a la que pertenece la cola (para el WAL; 0 = suelta).
+	fase int
+
+	vida *vida // nil = no se para
+
+	enq    chan enqReq
+	deq    chan deqReq
+	cancel chan cancelReq
+	retry  chan struct{}
+	claim  chan claimReq
+	snap   chan chan QueueSnapshot
+}
+
+// QueueSnapshot es una foto de la cola: coches por categoría y peticiones pendientes.
+type QueueSnapshot struct {
+	Capacidad  int     `json:"capacidad"`
+	A          []Coche `json:"A"`
+	B          []Coche `json:"B"`
+	C          []Coche `json:"C"`
+	Pendientes int     `json:"pendientes"` // encolados esperando hueco
+	Esperando  int     `json:"esperando"`  // workers esperando coche
+
+	Longitud  int           `json:"longitud"`
+	EsperaMax time.Duration `json:"esperaMax"` // lo que lleva en cola el coche más antiguo
 }
 
 type enqReq struct {
 	car   Coche
+	front bool          // al principio de su categoría y sin esperar hueco
 	reply chan struct{} // se cierra cuando el coche queda encolado
 }
 
 type deqReq struct {
-	state TallerState
-	reply chan Coche
+	state   TallerState
+	accept  func(Coche) bool // nil = cualquier coche
+	reply   chan Coche       // con buffer 1: la cola nunca se bloquea entregando
+	renovar chan struct{}    // se cierra si con Reintentar hay que volver a pedir (nil = no)
+}
+
+type claimReq struct {
+	state  TallerState
+	accept func(Coche) bool
+	reply  chan bool
+}
+
+type cancelReq struct {
+	reply chan Coche // identifica el deqReq a retirar
+	done  chan struct{}
 }
 
 // NewPhaseQueue crea una cola con capacidad máxima y arranca su goroutine interna.
 func NewPhaseQueue(capacity int) *PhaseQueue {
+	return newPhaseQueue(capacity, false, 0, nil)
+}
+
+// NewPhaseQueueEDF crea una cola que ordena por plazo de entrega (EDF).
+func NewPhaseQueueEDF(capacity int) *PhaseQueue {
+	return newPhaseQueue(capacity, true, 0, nil)
+}
+
+func newPhaseQueue(capacity int, edf bool, fase int, v *vida) *PhaseQueue {
 	q := &PhaseQueue{
 		capacity: capacity,
+		edf:      edf,
+		fase:     fase,
+		vida:     v,
 		enq:      make(chan enqReq),
 		deq:      make(chan deqReq),
+		cancel:   make(chan cancelReq),
+		retry:    make(chan struct{}),
+		claim:    make(chan claimReq),
+		snap:     make(chan chan QueueSnapshot),
 	}
-	go q.loop()
+	v.lanzar(q.loop)
 	return q
 }
 
 // Enqueue bloquea hasta que el coche se encola (si está llena, espera hueco).
 func (q *PhaseQueue) Enqueue(c Coche) {
 	done := make(chan struct{})
-	q.enq <- enqReq{car: c, reply: done}
-	<-done
+	enviar(q.vida, q.enq, enqReq{car: c, reply: done})
+	recibir(q.vida, done)
+}
+
+// EnqueueFront devuelve un coche al principio de su categoría (p.ej. tras un
+// servicio abortado). No espera hueco: el coche ya había sido admitido.
+func (q *PhaseQueue) EnqueueFront(c Coche) {
+	done := make(chan struct{})
+	enviar(q.vida, q.enq, enqReq{car: c, front: true, reply: done})
+	recibir(q.vida, done)
 }
 
 // Dequeue bloquea hasta que haya un coche disponible y lo devuelve.
 // La elección respeta el estado actual (prioridad/solo categoría).
 func (q *PhaseQueue) Dequeue(state TallerState) Coche {
-	reply := make(chan Coche)
-	q.deq <- deqReq{state: state, reply: reply}
-	return <-reply
+	reply := make(chan Coche, 1)
+	enviar(q.vida, q.deq, deqReq{state: state, reply: reply})
+	return recibir(q.vida, reply)
+}
+
+// DequeueOrStop es como Dequeue pero solo entrega coches que acepte accept
+// (nil = cualquiera) y deja de esperar si se cierra stop.
+// Devuelve false si se paró sin coche.
+func (q *PhaseQueue) DequeueOrStop(state TallerState, accept func(Coche) bool, stop <-chan struct{}) (Coche, bool) {
+	reply := make(chan Coche, 1)
+	enviar(q.vida, q.deq, deqReq{state: state, accept: accept, reply: reply})
+
+	select {
+	case car := <-reply:
+		return car, true
+	case <-stop:
+		return q.cancelar(reply)
+	case <-q.vida.parada():
+	}
+	runtime.Goexit()
+	return Coche{}, false
+}
+
+// DequeueRenovando es DequeueOrStop para un accept que depende de algo de
+// fuera de la cola (p.ej. el stock de piezas): aceptar() lo da quien llama,
+// y cada Reintentar le hace volver a pedir con uno nuevo. Así la cola nunca
+// tiene que preguntar a otro actor desde su goroutine.
+func (q *PhaseQueue) DequeueRenovando(state TallerState, aceptar func() func(Coche) bool, stop <-chan struct{}) (Coche, bool) {
+	for {
+		reply := make(chan Coche, 1)
+		renovar := make(chan struct{})
+		enviar(q.vida, q.deq, deqReq{state: state, accept: aceptar(), reply: reply, renovar: renovar})
+
+		select {
+		case car := <-reply:
+			return car, true
+		case <-renovar:
+			// La cola ya lo ha quitado de los que esperan: nadie más
+			// escribe en reply.
+		case <-stop:
+			return q.cancelar(reply)
+		case <-q.vida.parada():
+			runtime.Goexit()
+		}
+	}
+}
+
+// cancelar retira un dequeue en espera. Puede que el coche llegara justo
+// antes de cancelar: no se pierde.
+func (q *PhaseQueue) cancelar(reply chan Coche) (Coche, bool) {
+	done := make(chan struct{})
+	enviar(q.vida, q.cancel, cancelReq{reply: reply, done: done})
+	recibir(q.vida, done)
+	select {
+	case car := <-reply:
+		return car, true
+	default:
+		return Coche{}, false
+	}
+}
+
+// Reintentar vuelve a ofrecer los coches en cola a los workers que esperan,
+// y los de DequeueRenovando vuelven a pedir. Sirve cuando cambia algo de
+// fuera que decide accept (p.ej. llegan piezas).
+func (q *PhaseQueue) Reintentar() {
+	enviar(q.vida, q.retry, struct{}{})
+}
+
+// Reclamar indica si hay en cola un coche urgente que un worker ocupado podría
+// atender con este estado y nadie más espera coche. Si lo hay, lo reserva para
+// que solo un worker deje su coche por él (la reserva dura hasta que sale).
+func (q *PhaseQueue) Reclamar(state TallerState, accept func(Coche) bool) bool {
+	reply := make(chan bool, 1)
+	enviar(q.vida, q.claim, claimReq{state: state, accept: accept, reply: reply})
+	return recibir(q.vida, reply)
+}
+
+// antesPlazo indica si x tiene una entrega prometida anterior a la de y.
+// Un coche sin plazo nunca va antes que uno con plazo.
+func antesPlazo(x, y Coche) bool {
+	if x.Plazo == 0 {
+		return false
+	}
+	return y.Plazo == 0 || x.Plazo < y.Plazo
+}
+
+// Snapshot devuelve una copia del contenido actual de la cola.
+func (q *PhaseQueue) Snapshot() QueueSnapshot {
+	reply := make(chan QueueSnapshot, 1)
+	enviar(q.vida, q.snap, reply)
+	return recibir(q.vida, reply)
 }
 
 // loop mantiene las colas internas y resuelve encolados y desencolados.
@@ -57,103 +214,127 @@ func (q *PhaseQueue) loop() {
 	// Peticiones de dequeue pendientes cuando no hay coches.
 	var waiting []deqReq
 
+	// Momento (reloj de simulación) en que entró en cola cada coche.
+	since := map[int]time.Time{}
+
+	// Coches urgentes por los que un worker ya ha dejado (o va a dejar) el suyo.
+	reclamados := map[int]bool{}
+
 	totalLen := func() int { return len(a) + len(b) + len(c) }
 	hasAny := func() bool { return totalLen() > 0 }
 
-	// Selecciona el siguiente coche en función del estado.
-	pick := func(st TallerState) (Coche, bool) {
-		// Si hay "solo categoría", solo sacamos de esa.
-		if st.SoloCategoria != "" {
-			switch st.SoloCategoria {
-			case CatA:
-				if len(a) > 0 {
-					x := a[0]
-					a = a[1:]
-					return x, true
+	// Cola interna de una categoría.
+	cola := func(cat string) *[]Coche {
+		switch cat {
+		case CatA:
+			return &a
+		case CatB:
+			return &b
+		default:
+			return &c
+		}
+	}
+
+	// Saca de las colas ls el siguiente coche que acepte el worker (accept nil
+	// = cualquiera): el primero por orden de colas o, en EDF, el de plazo más
+	// cercano (los que no tienen plazo van al final; a igualdad, el primero).
+	popNext := func(accept func(Coche) bool, ls ...*[]Coche) (Coche, bool) {
+		var best *[]Coche
+		bestIdx := -1
+		for _, l := range ls {
+			for i, x := range *l {
+				if accept != nil && !accept(x) {
+					continue
 				}
-			case CatB:
-				if len(b) > 0 {
-					x := b[0]
-					b = b[1:]
+				if !q.edf {
+					*l = append((*l)[:i], (*l)[i+1:]...)
 					return x, true
 				}
-			case CatC:
-				if len(c) > 0 {
-					x := c[0]
-					c = c[1:]
-					return x, true
+				if best == nil || antesPlazo(x, (*best)[bestIdx]) {
+					best, bestIdx = l, i
 				}
 			}
+		}
+		if best == nil {
 			return Coche{}, false
 		}
+		x := (*best)[bestIdx]
+		*best = append((*best)[:bestIdx], (*best)[bestIdx+1:]...)
+		return x, true
+	}
 
-		// Si hay prioridad forzada, esa categoría va primero.
-		if st.PrioridadCategoria != "" {
-			switch st.PrioridadCategoria {
-			case CatA:
-				if len(a) > 0 {
-					x := a[0]
-					a = a[1:]
-					return x, true
-				}
-			case CatB:
-				if len(b) > 0 {
-					x := b[0]
-					b = b[1:]
-					return x, true
-				}
-			case CatC:
-				if len(c) > 0 {
-					x := c[0]
-					c = c[1:]
-					return x, true
-				}
-			}
-			// Si la prioritaria está vacía, seguimos orden normal.
+	// Colas de las que se puede sacar con el estado: si hay "solo categoría", solo esa.
+	permitidas := func(st TallerState) []*[]Coche {
+		if st.SoloCategoria != "" {
+			return []*[]Coche{cola(st.SoloCategoria)}
 		}
+		return []*[]Coche{&a, &b, &c}
+	}
 
-		// Orden normal A -> B -> C (A es la más prioritaria).
-		if len(a) > 0 {
-			x := a[0]
-			a = a[1:]
+	// Selecciona el siguiente coche en función del estado y de lo que
+	// sabe atender el worker. Los urgentes van antes que nadie.
+	pick := func(st TallerState, accept func(Coche) bool) (Coche, bool) {
+		urgente := func(x Coche) bool { return x.Urgente && (accept == nil || accept(x)) }
+		if x, ok := popNext(urgente, permitidas(st)...); ok {
 			return x, true
 		}
-		if len(b) > 0 {
-			x := b[0]
-			b = b[1:]
-			return x, true
+
+		// Si hay "solo categoría", solo sacamos de esa.
+		if st.SoloCategoria != "" {
+			return popNext(accept, cola(st.SoloCategoria))
 		}
-		if len(c) > 0 {
-			x := c[0]
-			c = c[1:]
-			return x, true
+
+		// Si hay prioridad forzada, esa categoría va primero.
+		// Si la prioritaria está vacía, seguimos orden normal.
+		if st.PrioridadCategoria != "" {
+			if x, ok := popNext(accept, cola(st.PrioridadCategoria)); ok {
+				return x, true
+			}
 		}
-		return Coche{}, false
+
+		// Orden normal A -> B -> C (A es la más prioritaria), o EDF entre las tres.
+		return popNext(accept, &a, &b, &c)
+	}
+
+	// take es pick + olvidar cuándo entró el coche.
+	take := func(st TallerState, accept func(Coche) bool) (Coche, bool) {
+		car, ok := pick(st, accept)
+		if ok {
+			delete(since, car.ID)
+			delete(reclamados, car.ID)
+			walLog.anotarCoche(WALDesencola, q.fase, car)
+		}
+		return car, ok
 	}
 
 	// Encola el coche en su cola por categoría.
 	push := func(car Coche) {
-		switch car.Categoria {
-		case CatA:
-			a = append(a, car)
-		case CatB:
-			b = append(b, car)
-		default:
-			c = append(c, car)
-		}
+		walLog.anotarCoche(WALEncola, q.fase, car)
+		since[car.ID] = time.Now()
+		l := cola(car.Categoria)
+		*l = append(*l, car)
+	}
+
+	// Mete el coche el primero de su categoría.
+	pushFront := func(car Coche) {
+		walLog.Anotar(EventoWAL{Tipo: WALEncola, Fase: q.fase, Coche: &car, Frente: true})
+		since[car.ID] = time.Now()
+		l := cola(car.Categoria)
+		*l = append([]Coche{car}, *l...)
 	}
 
-	// Intenta resolver dequeues en espera mientras haya coches.
+	// Intenta resolver dequeues en espera por orden de llegada. Un worker que
+	// no sabe atender ningún coche de la cola no bloquea a los de detrás.
 	flushWaiting := func() {
-		for len(waiting) > 0 {
-			req := waiting[0]
-			car, ok := pick(req.state)
-			if !ok {
-				return
+		rest := waiting[:0]
+		for _, req := range waiting {
+			if car, ok := take(req.state, req.accept); ok {
+				req.reply <- car
+			} else {
+				rest = append(rest, req)
 			}
-			waiting = waiting[1:]
-			req.reply <- car
 		}
+		waiting = rest
 	}
 
 	// Intenta meter encolados pendientes si hay hueco.
@@ -168,7 +349,18 @@ func (q *PhaseQueue) loop() {
 
 	for {
 		select {
+		case <-q.vida.parada():
+			return
+
 		case r := <-q.enq:
+			// Los devueltos al principio entran siempre.
+			if r.front {
+				pushFront(r.car)
+				close(r.reply)
+				flushWaiting()
+				continue
+			}
+
 			// Si hay hueco, encolamos; si no, guardamos como pendiente.
 			if totalLen() < q.capacity {
 				push(r.car)
@@ -184,7 +376,7 @@ func (q *PhaseQueue) loop() {
 		case r := <-q.deq:
 			// Si hay coches, sacamos según el estado. Si no hay, se queda esperando.
 			if hasAny() {
-				car, ok := pick(r.state)
+				car, ok := take(r.state, r.accept)
 				if ok {
 					r.reply <- car
 					// Tras sacar, puede haber hueco: metemos pendientes.
@@ -196,6 +388,65 @@ func (q *PhaseQueue) loop() {
 			} else {
 				waiting = append(waiting, r)
 			}
+
+		case r := <-q.cancel:
+			for i, w := range waiting {
+				if w.reply == r.reply {
+					waiting = append(waiting[:i], waiting[i+1:]...)
+					break
+				}
+			}
+			close(r.done)
+
+		case <-q.retry:
+			rest := waiting[:0]
+			for _, req := range waiting {
+				if req.renovar != nil {
+					close(req.renovar)
+					continue
+				}
+				rest = append(rest, req)
+			}
+			waiting = rest
+			flushWaiting()
+			flushPending()
+
+		case r := <-q.claim:
+			// Con workers libres esperando, el urgente ya tiene quien lo coja.
+			ok := false
+			if len(waiting) == 0 {
+			buscar:
+				for _, l := range permitidas(r.state) {
+					for _, x := range *l {
+						if x.Urgente && !reclamados[x.ID] && (r.accept == nil || r.accept(x)) {
+							reclamados[x.ID] = true
+							ok = true
+							break buscar
+						}
+					}
+				}
+			}
+			r.reply <- ok
+
+		case reply := <-q.snap:
+			var esperaMax time.Duration
+			for _, t := range since {
+				if d := simSince(t); d > esperaMax {
+					esperaMax = d
+				}
+			}
+
+			// Copias para que nadie toque las colas fuera de esta goroutine.
+			reply <- QueueSnapshot{
+				Capacidad:  q.capacity,
+				A:          append([]Coche{}, a...),
+				B:          append([]Coche{}, b...),
+				C:          append([]Coche{}, c...),
+				Pendientes: len(pending),
+				Esperando:  len(waiting),
+				Longitud:   totalLen(),
+				EsperaMax:  esperaMax,
+			}
 		}
 	}
 }
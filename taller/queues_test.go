@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Un worker solo recibe coches que sabe atender, y un worker esperando
+// sin coche elegible no bloquea a los que llegan detrás.
+func TestPhaseQueue_FiltraPorPerfil(t *testing.T) {
+	q := NewPhaseQueue(10)
+	normal := TallerState{Activo: true}
+	soloB := Perfil{CatB: 1}
+	soloC := Perfil{CatC: 1}
+
+	q.Enqueue(Coche{ID: 1, Categoria: CatA})
+	q.Enqueue(Coche{ID: 2, Categoria: CatB})
+
+	// Aunque A va antes, el especialista en B se lleva el B.
+	if car, ok := q.DequeueOrStop(normal, soloB.Puede, nil); !ok || car.ID != 2 {
+		t.Fatalf("esperaba coche 2, got %+v", car)
+	}
+
+	// El especialista en C se queda esperando...
+	gotC := make(chan Coche, 1)
+	go func() {
+		car, _ := q.DequeueOrStop(normal, soloC.Puede, nil)
+		gotC <- car
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// ...y no impide que un generalista saque el A.
+	if car := q.Dequeue(normal); car.ID != 1 {
+		t.Fatalf("esperaba coche 1, got %+v", car)
+	}
+
+	q.Enqueue(Coche{ID: 3, Categoria: CatC})
+	select {
+	case car := <-gotC:
+		if car.ID != 3 {
+			t.Fatalf("esperaba coche 3, got %+v", car)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("el especialista en C no recibió su coche")
+	}
+}
+
+// Con DequeueRenovando, el accept lo calcula el worker; tras Reintentar
+// vuelve a pedir con uno nuevo y se lleva el coche que antes no podía.
+func TestPhaseQueue_Renovando(t *testing.T) {
+	q := NewPhaseQueue(10)
+	normal := TallerState{Activo: true}
+	q.Enqueue(Coche{ID: 1, Categoria: CatA})
+
+	hay := make(chan bool, 2) // lo que ve el worker cada vez que pide
+	hay <- false
+	hay <- true
+	pedidos := 0
+	aceptar := func() func(Coche) bool {
+		pedidos++
+		ok := <-hay
+		return func(Coche) bool { return ok }
+	}
+
+	got := make(chan Coche, 1)
+	go func() {
+		car, _ := q.DequeueRenovando(normal, aceptar, nil)
+		got <- car
+	}()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case car := <-got:
+		t.Fatalf("se lleva %+v sin poder", car)
+	default:
+	}
+
+	q.Reintentar()
+	select {
+	case car := <-got:
+		if car.ID != 1 || pedidos != 2 {
+			t.Fatalf("coche %+v tras %d pedidos", car, pedidos)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no vuelve a pedir tras Reintentar")
+	}
+}
+
+func TestPerfil_Duracion(t *testing.T) {
+	p := Perfil{CatA: 0.5, CatB: 1}
+	if d := p.Duracion(CatA, 4*time.Second); d != 2*time.Second {
+		t.Fatalf("A: got %v", d)
+	}
+	if d := Perfil(nil).Duracion(CatC, time.Second); d != time.Second {
+		t.Fatalf("generalista: got %v", d)
+	}
+	if !Perfil(nil).Puede(Coche{Categoria: CatC}) || p.Puede(Coche{Categoria: CatC}) {
+		t.Fatal("Puede no respeta el perfil")
+	}
+}
+
+// Se encogen los mecánicos de 3 a 2 con el tercero aún acabando su coche, y
+// luego se vuelve a 3: el nuevo no puede compartir el hueco 3 con él. Cuando
+// el retirado acaba, su hueco vuelve a estar libre.
+func TestHuecos_NoSeComparten(t *testing.T) {
+	hs := huecos{}
+	for want := 1; want <= 3; want++ {
+		if h := hs.coger(RecursoMecanicos); h.n != want {
+			t.Fatalf("worker %d: hueco %d", want, h.n)
+		}
+	}
+	if h := hs.coger(RecursoLimpieza); h.n != 1 {
+		t.Fatalf("limpieza: hueco %d, cada recurso tiene los suyos", h.n)
+	}
+
+	// El tercero sigue vivo (retirado, acabando): el nuevo va al 4.
+	if h := hs.coger(RecursoMecanicos); h.n != 4 {
+		t.Fatalf("con el 3 ocupado: hueco %d", h.n)
+	}
+	hs.soltar(hueco{RecursoMecanicos, 3})
+	if h := hs.coger(RecursoMecanicos); h.n != 3 {
+		t.Fatalf("con el 3 ya libre: hueco %d", h.n)
+	}
+}
+
+// EDF saca primero el plazo más cercano (los urgentes antes que nadie y los
+// que no tienen plazo al final), dentro de lo que permite el estado; la cola
+// por categorías sigue A->B->C.
+func TestPhaseQueue_EDF(t *testing.T) {
+	coches := []Coche{
+		{ID: 1, Categoria: CatA},
+		{ID: 2, Categoria: CatA, Plazo: 50 * time.Second},
+		{ID: 3, Categoria: CatB, Plazo: 10 * time.Second},
+		{ID: 4, Categoria: CatC, Plazo: 30 * time.Second},
+		{ID: 5, Categoria: CatC, Plazo: 10 * time.Second},
+		{ID: 6, Categoria: CatC, Plazo: 90 * time.Second, Urgente: true},
+	}
+	normal := TallerState{Activo: true}
+
+	for _, tc := range []struct {
+		edf   bool
+		state TallerState
+		orden []int
+	}{
+		// A igualdad de plazo (3 y 5), el primero de las colas.
+		{true, normal, []int{6, 3, 5, 4, 2, 1}},
+		{true, TallerState{Activo: true, SoloCategoria: CatC}, []int{6, 5, 4}},
+		{false, normal, []int{6, 1, 2, 3, 4, 5}},
+	} {
+		q := NewPhaseQueue(10)
+		if tc.edf {
+			q = NewPhaseQueueEDF(10)
+		}
+		for _, c := range coches {
+			q.Enqueue(c)
+		}
+		var got []int
+		for range tc.orden {
+			got = append(got, q.Dequeue(tc.state).ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.orden) {
+			t.Errorf("edf=%v %s: %v, quería %v", tc.edf, stateSummary(tc.state), got, tc.orden)
+		}
+	}
+
+	for _, tc := range []struct {
+		x, y time.Duration
+		want bool
+	}{
+		{10 * time.Second, 20 * time.Second, true},
+		{20 * time.Second, 10 * time.Second, false},
+		{10 * time.Second, 10 * time.Second, false},
+		{10 * time.Second, 0, true}, // sin plazo, al final
+		{0, 10 * time.Second, false},
+		{0, 0, false},
+	} {
+		if got := antesPlazo(Coche{Plazo: tc.x}, Coche{Plazo: tc.y}); got != tc.want {
+			t.Errorf("antesPlazo(%v, %v) = %v", tc.x, tc.y, got)
+		}
+	}
+}
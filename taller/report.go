@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Informe acumula métricas de una ejecución a partir de los LogEvent.
+// No usa mutex: lo alimenta una sola goroutine (logger, dashboard o test).
+type Informe struct {
+	Entregados int
+
+	Retrabajos         int
+	RetrabajosPorCoche map[int]int    // coche -> nº de retrabajos
+	RetrabajosPorInc   map[string]int // incidencia -> nº de retrabajos
+
+	// Coches que dejaron el servicio a medias por uno urgente o por SOLO X.
+	Expropiaciones int
+
+	// Decisiones del autoescalado.
+	Escalados int
+
+	// Averías de workers a mitad de un coche.
+	Averias int
+
+	// Agenda de citas.
+	Reservas    int
+	Sobreventas int
+	Rechazos    []string // motivo de cada rechazo
+
+	// Inventario de repuestos: roturas de stock por pieza y cuánto han
+	// esperado los mecánicos por piezas, frente a la espera total entre
+	// la plaza y el mecánico (para ver si el cuello de botella son las piezas).
+	Agotados      map[string]int           // pieza -> veces que se agotó
+	TiempoAgotado map[string]time.Duration // pieza -> tiempo sin stock
+	Pedidos       int
+	EsperasPiezas int
+	EsperaPiezas  time.Duration
+	EsperaFase1   time.Duration
+	agotadaDesde  map[string]time.Duration
+	salePlaza     map[int]time.Duration // coche -> cuándo dejó la plaza
+	ultimo        time.Duration         // instante del último evento
+
+	// Facturación (con Config.Costes).
+	Facturado  map[string]float64 // incidencia -> importe cobrado en las entregas
+	ManoDeObra map[string]float64 // incidencia -> coste de las fases
+	Ocioso     map[int]float64    // fase -> coste de su recurso parado
+
+	// SLA: coches entregados con plazo y cuántos a tiempo, por incidencia.
+	ConPlazo map[string]int
+	ATiempo  map[string]int
+	Tarde    []Retraso
+}
+
+// Retraso de un coche entregado después de su plazo.
+type Retraso struct {
+	CocheID    int
+	Incidencia string
+	Retraso    time.Duration
+}
+
+// Tramos del histograma de retrasos (el último tramo es "más de").
+var tramosRetraso = []time.Duration{10 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute}
+
+func NewInforme() *Informe {
+	return &Informe{
+		RetrabajosPorCoche: map[int]int{},
+		RetrabajosPorInc:   map[string]int{},
+		Agotados:           map[string]int{},
+		TiempoAgotado:      map[string]time.Duration{},
+		agotadaDesde:       map[string]time.Duration{},
+		salePlaza:          map[int]time.Duration{},
+		Facturado:          map[string]float64{},
+		ManoDeObra:         map[string]float64{},
+		Ocioso:             map[int]float64{},
+		ConPlazo:           map[string]int{},
+		ATiempo:            map[string]int{},
+	}
+}
+
+// Observar incorpora un evento al informe.
+func (in *Informe) Observar(ev LogEvent) {
+	in.ultimo = max(in.ultimo, ev.Elapsed)
+	// Mano de obra: la del Sale de cada fase y la de los servicios que se
+	// dejan a medias (Expropiado, Abortado, Averia).
+	if ev.CocheID != 0 && ev.Coste > 0 {
+		in.ManoDeObra[ev.Incidencia] += ev.Coste
+	}
+	switch ev.Estado {
+	case EstadoEntra:
+		if t, ok := in.salePlaza[ev.CocheID]; ok && ev.Fase == FaseMecanico {
+			in.EsperaFase1 += ev.Elapsed - t
+			delete(in.salePlaza, ev.CocheID)
+		}
+	case EstadoSale:
+		if ev.Importe > 0 {
+			in.Facturado[ev.Incidencia] += ev.Importe
+		}
+		if ev.Fase == FaseEsperaPlaza {
+			in.salePlaza[ev.CocheID] = ev.Elapsed
+		}
+		if ev.Fase != FaseEntrega {
+			break
+		}
+		in.Entregados++
+		if ev.Plazo > 0 {
+			in.ConPlazo[ev.Incidencia]++
+			if ev.Elapsed <= ev.Plazo {
+				in.ATiempo[ev.Incidencia]++
+			} else {
+				in.Tarde = append(in.Tarde, Retraso{CocheID: ev.CocheID, Incidencia: ev.Incidencia, Retraso: ev.Elapsed - ev.Plazo})
+			}
+		}
+	case EstadoReserva:
+		in.Reservas++
+	case EstadoSobreventa:
+		in.Reservas++
+		in.Sobreventas++
+	case EstadoRechazo:
+		in.Rechazos = append(in.Rechazos, ev.Detalle)
+	case EstadoAgotado:
+		in.Agotados[ev.Pieza]++
+		in.agotadaDesde[ev.Pieza] = ev.Elapsed
+	case EstadoRepuesto:
+		if t, ok := in.agotadaDesde[ev.Pieza]; ok {
+			in.TiempoAgotado[ev.Pieza] += ev.Elapsed - t
+			delete(in.agotadaDesde, ev.Pieza)
+		}
+	case EstadoPedido:
+		in.Pedidos++
+	case EstadoPiezas:
+		in.EsperasPiezas++
+		in.EsperaPiezas += ev.Espera
+	case EstadoExpropiado:
+		in.Expropiaciones++
+	case EstadoEscalado:
+		in.Escalados++
+	case EstadoAveria:
+		in.Averias++
+	case EstadoOcioso:
+		in.Ocioso[ev.Fase] += ev.Coste
+	case EstadoRetrabajo:
+		in.Retrabajos++
+		in.RetrabajosPorCoche[ev.CocheID]++
+		in.RetrabajosPorInc[ev.Incidencia]++
+	}
+}
+
+// String da el informe en texto para imprimirlo o servirlo por la API.
+func (in *Informe) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INFORME\n")
+	fmt.Fprintf(&b, "Coches entregados: %d\n", in.Entregados)
+
+	fmt.Fprintf(&b, "Retrabajos: %d en %d coches", in.Retrabajos, len(in.RetrabajosPorCoche))
+	if in.Retrabajos > 0 {
+		fmt.Fprintf(&b, " (")
+		for i, inc := range incidencias() {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%s: %d", inc, in.RetrabajosPorInc[inc])
+		}
+		fmt.Fprintf(&b, ")")
+	}
+	b.WriteString("\n")
+
+	if in.Expropiaciones > 0 {
+		fmt.Fprintf(&b, "Expropiaciones: %d\n", in.Expropiaciones)
+	}
+	if in.Averias > 0 {
+		fmt.Fprintf(&b, "Averías: %d\n", in.Averias)
+	}
+	if in.Escalados > 0 {
+		fmt.Fprintf(&b, "Autoescalado: %d cambios de workers\n", in.Escalados)
+	}
+
+	if in.Reservas > 0 || len(in.Rechazos) > 0 {
+		fmt.Fprintf(&b, "Citas: %d aceptadas (%d en sobreventa), %d rechazadas\n", in.Reservas, in.Sobreventas, len(in.Rechazos))
+		for _, r := range in.Rechazos {
+			fmt.Fprintf(&b, "  %s\n", r)
+		}
+	}
+
+	if in.Pedidos > 0 || len(in.Agotados) > 0 {
+		in.escribirPiezas(&b)
+	}
+
+	if len(in.ManoDeObra) > 0 || len(in.Ocioso) > 0 {
+		in.escribirFactura(&b)
+	}
+
+	if len(in.ConPlazo) > 0 {
+		in.escribirSLA(&b)
+	}
+	return b.String()
+}
+
+// escribirPiezas: roturas de stock por pieza y peso de la espera por piezas
+// dentro de la espera para entrar al mecánico.
+func (in *Informe) escribirPiezas(b *strings.Builder) {
+	fmt.Fprintf(b, "Piezas: %d pedidos, %d coches esperaron piezas (%v de %v de espera antes del mecánico)\n",
+		in.Pedidos, in.EsperasPiezas, in.EsperaPiezas.Truncate(time.Millisecond), in.EsperaFase1.Truncate(time.Millisecond))
+
+	piezas := make([]string, 0, len(in.Agotados))
+	for p := range in.Agotados {
+		piezas = append(piezas, p)
+	}
+	sort.Strings(piezas)
+	for _, p := range piezas {
+		// Si sigue agotada, cuenta hasta el último evento visto.
+		sin := in.TiempoAgotado[p]
+		if t, ok := in.agotadaDesde[p]; ok {
+			sin += in.ultimo - t
+		}
+		fmt.Fprintf(b, "  %-10s agotada %d veces, %v sin stock\n", p, in.Agotados[p], sin.Truncate(time.Millisecond))
+	}
+}
+
+// escribirFactura: lo cobrado y la mano de obra por incidencia, lo que han
+// costado los recursos parados y el margen que queda.
+func (in *Informe) escribirFactura(b *strings.Builder) {
+	fmt.Fprintf(b, "Factura:\n")
+	var facturado, manoDeObra, ocioso float64
+	for _, inc := range incidencias() {
+		f, m := in.Facturado[inc], in.ManoDeObra[inc]
+		facturado += f
+		manoDeObra += m
+		fmt.Fprintf(b, "  %-10s facturado %10.2f  mano de obra %10.2f\n", inc, f, m)
+	}
+
+	fmt.Fprintf(b, "  Recursos parados:")
+	for fase, recurso := range []string{RecursoPlazas, RecursoMecanicos, RecursoLimpieza, RecursoEntrega} {
+		ocioso += in.Ocioso[fase]
+		fmt.Fprintf(b, " %s %.2f", recurso, in.Ocioso[fase])
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(b, "  Total: facturado %.2f, mano de obra %.2f, parados %.2f, margen %.2f\n",
+		facturado, manoDeObra, ocioso, facturado-manoDeObra-ocioso)
+}
+
+// escribirSLA: % a tiempo por incidencia, distribución de retrasos y coches tarde.
+func (in *Informe) escribirSLA(b *strings.Builder) {
+	fmt.Fprintf(b, "SLA (entregas con plazo):\n")
+	for _, inc := range incidencias() {
+		n := in.ConPlazo[inc]
+		if n == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "  %-10s %d/%d a tiempo (%.1f%%)\n", inc, in.ATiempo[inc], n, 100*float64(in.ATiempo[inc])/float64(n))
+	}
+	if len(in.Tarde) == 0 {
+		return
+	}
+
+	tarde := append([]Retraso{}, in.Tarde...)
+	sort.Slice(tarde, func(i, j int) bool { return tarde[i].Retraso < tarde[j].Retraso })
+	pct := func(p float64) time.Duration { return tarde[int(p*float64(len(tarde)-1))].Retraso }
+	fmt.Fprintf(b, "  Retraso: p50=%v p90=%v max=%v\n",
+		pct(0.5).Truncate(time.Millisecond), pct(0.9).Truncate(time.Millisecond), tarde[len(tarde)-1].Retraso.Truncate(time.Millisecond))
+
+	// Histograma por tramos.
+	hist := make([]int, len(tramosRetraso)+1)
+	for _, r := range tarde {
+		i := sort.Search(len(tramosRetraso), func(i int) bool { return r.Retraso <= tramosRetraso[i] })
+		hist[i]++
+	}
+	desde := time.Duration(0)
+	for i, hasta := range tramosRetraso {
+		fmt.Fprintf(b, "  (%v, %v]: %d\n", desde, hasta, hist[i])
+		desde = hasta
+	}
+	fmt.Fprintf(b, "  > %v: %d\n", desde, hist[len(tramosRetraso)])
+
+	fmt.Fprintf(b, "  Coches tarde:")
+	for _, r := range tarde {
+		fmt.Fprintf(b, " %d(%s +%v)", r.CocheID, r.Incidencia, r.Retraso.Truncate(time.Millisecond))
+	}
+	b.WriteString("\n")
+}
+
+// incidencias en el orden en que se muestran en los informes.
+func incidencias() []string {
+	return []string{categoriaTipo(CatA), categoriaTipo(CatB), categoriaTipo(CatC)}
+}
@@ -1,36 +1,195 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+var (
+	httpAddr = flag.String("http", "", "dirección de la API HTTP de control (p.ej. localhost:8080); vacío = desactivada")
+	offline  = flag.Bool("offline", false, "no conectar al servidor: el estado solo se cambia por la API HTTP")
+	dashOn   = flag.Bool("dashboard", false, "muestra un panel ANSI refrescado en el sitio en vez de las trazas")
+	autoOn   = flag.Bool("autoescalado", false, "añade/quita workers según la longitud y espera de las colas")
+	diaSim   = flag.Duration("dia", 0, "duración de un día simulado para horarios y turnos (p.ej. 2m); 0 = sin horarios")
+	mtbf     = flag.Duration("mtbf", 0, "tiempo medio entre averías de los workers de las fases 1..3; 0 = sin averías")
+	mttr     = flag.Duration("mttr", 5*time.Second, "tiempo medio de reparación de una avería")
+	modoAv   = flag.String("averia", AveriaPausa, "qué hacer con el coche en una avería: pausa o abortar")
+	plazos   = flag.String("plazos", "", "plazo de entrega por categoría desde la llegada, p.ej. A=2m,B=1m,C=30s")
+	edf      = flag.Bool("edf", false, "colas EDF: primero el coche con el plazo de entrega más cercano")
+	llegadas = flag.String("llegadas", "", "fichero con la traza de llegadas (segundos,categoria[,plazo]); sustituye a los coches generados")
+	sobrev   = flag.Float64("sobreventa", 0, "fracción de sobreventa de la agenda de citas (0.2 = 20% por encima de la capacidad)")
+	plazoPzs = flag.Duration("piezas", 0, "plazo de reposición de repuestos de la fase de mecánico (p.ej. 10s); 0 = sin inventario")
+	saltar   = flag.Bool("saltar", false, "sin piezas, el mecánico coge otro coche en vez de esperar")
+	costesOn = flag.Bool("costes", false, "factura los coches con tarifas de ejemplo y cuenta el coste de los recursos parados")
+	exprop   = flag.Bool("expropiativo", false, "un coche urgente o SOLO X interrumpen al coche en servicio, que luego sigue donde iba")
+	enServ   = flag.String("enservicio", "", "qué hacer con el coche en servicio si cierran o cambia a SOLO X: terminar, pausar o abortar (para todas las fases o por fase: 1=pausar,2=abortar)")
+	ckptPath = flag.String("checkpoint", "", "fichero donde guardar cada 5s una foto de la simulación; vacío = sin checkpoints")
+	restore  = flag.Bool("restore", false, "retoma la simulación del último checkpoint (el de -checkpoint) en vez de empezar de cero")
+	walPath  = flag.String("wal", "", "fichero donde anotar (con checksum y fsync) cada código, encolado, desencolado, Entra y Sale; vacío = sin WAL")
+	replay   = flag.String("replay", "", "reconstruye desde el WAL dado el estado, las colas y los coches, lo muestra y sale")
+	perfiles = flag.String("perfiles", "", "perfiles de los workers por recurso, p.ej. mecanicos=A,C/A,B:1.2;entrega=* (/ separa workers, * = generalista, :x multiplica el tiempo)")
+	probInsp = flag.Float64("inspeccion", 0, "probabilidad de no pasar la inspección tras limpieza (vuelve a mecánico); 0 = sin inspección")
+)
+
 var (
 	startOnce sync.Once
 
 	incomingMsgCh chan string
-	stateCodeCh   chan int
+	stateCodeCh   chan Codigo
 	stateQueryCh  chan stateRequest
 
 	logCh     chan LogEvent
+	informeCh chan chan string
 	startTime time.Time
+
+	sim *Simulation
 )
 
 func initRuntime() {
+	// Con el panel, las trazas se callan antes de arrancar nada que las escriba.
+	if *dashOn {
+		debugOut = io.Discard
+	}
+
 	incomingMsgCh = make(chan string, 32)
-	stateCodeCh = make(chan int, 16)
+	stateCodeCh = make(chan Codigo, 16)
 	stateQueryCh = make(chan stateRequest)
 	logCh = make(chan LogEvent, 1024)
+	informeCh = make(chan chan string)
 
 	startTime = time.Now()
 
+	// Al restaurar, el reloj y el estado siguen donde se quedaron.
+	var cp *Checkpoint
+	if *restore {
+		if *ckptPath == "" {
+			log.Fatal("-restore necesita -checkpoint")
+		}
+		var err error
+		if cp, err = LoadCheckpoint(*ckptPath); err != nil {
+			log.Fatal(err)
+		}
+		startTime = startTime.Add(-cp.Tiempo)
+		debugln("RESTAURADO:", *ckptPath, "tiempo", cp.Tiempo, "coches", len(cp.Coches), "estado", stateSummary(cp.Estado))
+	}
+
+	// El WAL se abre antes de arrancar nada que escriba en él. Al restaurar
+	// se sigue el mismo; si no, se empieza de cero.
+	if *walPath != "" {
+		w, err := AbrirWAL(*walPath, startTime, *restore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		walLog = w
+	}
+
 	go parseIncoming(incomingMsgCh, stateCodeCh)
-	go controller(stateCodeCh, stateQueryCh)
-	go runLogger(logCh)
+	// El estado por defecto es la base de la fusión: el de partida y al que
+	// se vuelve cuando caducan los estados de todas las fuentes. Al
+	// restaurar se parte del guardado, que no lo cambia.
+	base := defaultState()
+	if *defecto >= 0 {
+		if *defecto > 9 {
+			log.Fatal("-defecto: el código debe estar entre 0 y 9")
+		}
+		base.applyCode(*defecto)
+	}
+	fus, err := NuevaFusion(*fusion, parsePrecedencia(*precedencia), base)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cp != nil {
+		fus.Restaurar(cp.Estado)
+	}
+	go controller(fus, stateCodeCh, stateQueryCh)
 
 	// Config de desarrollo (luego en tests se pasará otro).
 	cfg := DefaultConfig()
-	go startSimulation(startTime, logCh, cfg)
+	if *autoOn {
+		cfg.Autoescalado = DefaultAutoscale()
+	}
+	if *diaSim > 0 {
+		cfg.Calendario = DefaultCalendario(*diaSim)
+	}
+	if *plazos != "" {
+		p, err := parsePlazos(*plazos)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Plazos = p
+	}
+	if *perfiles != "" {
+		p, err := parsePerfiles(*perfiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Perfiles = p
+	}
+	cfg.ColaEDF = *edf
+	if *llegadas != "" {
+		ls, err := LoadLlegadas(*llegadas)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Llegadas = ls
+	}
+	if *probInsp > 0 {
+		cfg.Inspecciones = map[int]Inspeccion{
+			FaseLimpieza: {
+				VuelveA:       FaseMecanico,
+				ProbFallo:     map[string]float64{CatA: *probInsp, CatB: *probInsp, CatC: *probInsp},
+				MaxRetrabajos: 2,
+			},
+		}
+	}
+	cfg.Sobreventa = *sobrev
+	cfg.Expropiativo = *exprop
+	if *enServ != "" {
+		p, err := parseEnServicio(*enServ)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.EnServicio = p
+	}
+	if *costesOn {
+		cfg.Costes = DefaultCostes()
+	}
+	if *plazoPzs > 0 {
+		cfg.Inventario = DefaultInventario(*plazoPzs)
+		cfg.Inventario.Saltar = *saltar
+	}
+	modo, err := parseAveria(*modoAv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *mtbf > 0 {
+		cfg.Averias = AveriasConfig{MTBF: map[string]time.Duration{}, MTTR: map[string]time.Duration{}, Modo: modo}
+		for _, r := range []string{RecursoMecanicos, RecursoLimpieza, RecursoEntrega} {
+			cfg.Averias.MTBF[r] = *mtbf
+			cfg.Averias.MTTR[r] = *mttr
+		}
+	}
+	cfg.Checkpoint = *ckptPath
+	cfg.Restaurar = cp
+	if sim, err = startSimulation(startTime, logCh, cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	if *dashOn {
+		go runDashboard(logCh, informeCh, sim, startTime)
+	} else {
+		go runLogger(logCh, informeCh)
+	}
+
+	if *httpAddr != "" {
+		go serveAPI(*httpAddr, sim, stateCodeCh, informeCh)
+	}
 }
 
 func dispatch(msg string) {
@@ -38,6 +197,153 @@ func dispatch(msg string) {
 	incomingMsgCh <- msg
 }
 
+// runOffline arranca el taller sin servidor ni mutua (pruebas locales con la API).
+func runOffline() {
+	startOnce.Do(initRuntime)
+	select {}
+}
+
+// runReplay muestra lo que dice el WAL de -replay sin arrancar la simulación.
+func runReplay() {
+	r, err := ReplayWAL(*replay)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(r)
+}
+
+// parsePlazos lee "A=2m,B=1m,C=30s".
+func parsePlazos(s string) (map[string]time.Duration, error) {
+	out := map[string]time.Duration{}
+	for _, par := range strings.Split(s, ",") {
+		cat, dur, ok := strings.Cut(strings.TrimSpace(par), "=")
+		if !ok {
+			return nil, fmt.Errorf("plazos: esperaba CATEGORIA=duración, no %q", par)
+		}
+		cat = strings.ToUpper(strings.TrimSpace(cat))
+		if cat != CatA && cat != CatB && cat != CatC {
+			return nil, fmt.Errorf("plazos: categoría desconocida %q", cat)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(dur))
+		if err != nil {
+			return nil, fmt.Errorf("plazos: %v", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("plazos: el plazo de %s debe ser positivo, no %v", cat, d)
+		}
+		out[cat] = d
+	}
+	return out, nil
+}
+
+// parsePerfiles lee "mecanicos=A/A/A,B:1.2;limpieza=C:0.5/*": por recurso,
+// el perfil de cada worker separado por "/", con las categorías que sabe
+// atender y su multiplicador del tiempo (1 si no se da); "*" es un
+// generalista.
+func parsePerfiles(s string) (map[string][]Perfil, error) {
+	out := map[string][]Perfil{}
+	for _, tramo := range strings.Split(s, ";") {
+		recurso, lista, ok := strings.Cut(strings.TrimSpace(tramo), "=")
+		recurso = strings.ToLower(strings.TrimSpace(recurso))
+		if !ok || lista == "" {
+			return nil, fmt.Errorf("perfiles: esperaba RECURSO=perfil/perfil/..., no %q", tramo)
+		}
+		if _, repetido := out[recurso]; repetido {
+			return nil, fmt.Errorf("perfiles: %s aparece dos veces", recurso)
+		}
+		for _, txt := range strings.Split(lista, "/") {
+			p, err := parsePerfil(strings.TrimSpace(txt))
+			if err != nil {
+				return nil, fmt.Errorf("perfiles: %s: %v", recurso, err)
+			}
+			out[recurso] = append(out[recurso], p)
+		}
+	}
+	return out, nil
+}
+
+// parsePerfil lee "A,B:1.2" o "*".
+func parsePerfil(s string) (Perfil, error) {
+	if s == "*" {
+		return nil, nil
+	}
+	p := Perfil{}
+	for _, item := range strings.Split(s, ",") {
+		cat, mult, conMult := strings.Cut(strings.TrimSpace(item), ":")
+		cat = strings.ToUpper(strings.TrimSpace(cat))
+		if cat != CatA && cat != CatB && cat != CatC {
+			return nil, fmt.Errorf("categoría desconocida %q", cat)
+		}
+		m := 1.0
+		if conMult {
+			var err error
+			if m, err = strconv.ParseFloat(strings.TrimSpace(mult), 64); err != nil || m <= 0 {
+				return nil, fmt.Errorf("el multiplicador de %s debe ser un número positivo, no %q", cat, mult)
+			}
+		}
+		p[cat] = m
+	}
+	return p, nil
+}
+
+// parseAveria comprueba el modo de avería: pausa o abortar.
+func parseAveria(s string) (string, error) {
+	switch s {
+	case AveriaPausa, AveriaAbortar:
+		return s, nil
+	}
+	return "", fmt.Errorf("averia: modo desconocido %q (pausa o abortar)", s)
+}
+
+// parseEnServicio lee "pausar" (todas las fases) o "0=terminar,1=pausar,2=abortar".
+func parseEnServicio(s string) (map[int]string, error) {
+	valida := func(p string) error {
+		switch p {
+		case EnServicioTerminar, EnServicioPausar, EnServicioAbortar:
+			return nil
+		}
+		return fmt.Errorf("enservicio: política desconocida %q (terminar, pausar o abortar)", p)
+	}
+
+	out := map[int]string{}
+	if !strings.Contains(s, "=") {
+		if err := valida(s); err != nil {
+			return nil, err
+		}
+		for fase := FaseEsperaPlaza; fase <= FaseEntrega; fase++ {
+			out[fase] = s
+		}
+		return out, nil
+	}
+	for _, par := range strings.Split(s, ",") {
+		f, p, ok := strings.Cut(strings.TrimSpace(par), "=")
+		fase, err := strconv.Atoi(f)
+		if !ok || err != nil || fase < FaseEsperaPlaza || fase > FaseEntrega {
+			return nil, fmt.Errorf("enservicio: esperaba FASE=política con fase 0..3, no %q", par)
+		}
+		if err := valida(p); err != nil {
+			return nil, err
+		}
+		out[fase] = p
+	}
+	return out, nil
+}
+
+// informeActual devuelve el informe de la ejecución hasta ahora.
+func informeActual() string {
+	reply := make(chan string, 1)
+	informeCh <- reply
+	return <-reply
+}
+
+// printInforme imprime el informe final (si la simulación llegó a arrancar).
+func printInforme() {
+	if sim == nil {
+		return
+	}
+	fmt.Print(informeActual())
+}
+
 func getState() TallerState {
 	reply := make(chan TallerState, 1)
 	stateQueryCh <- stateRequest{reply: reply}
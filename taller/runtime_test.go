@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Los modos de avería válidos pasan tal cual; cualquier otro es un error.
+func TestParseAveria(t *testing.T) {
+	for _, modo := range []string{AveriaPausa, AveriaAbortar} {
+		if got, err := parseAveria(modo); err != nil || got != modo {
+			t.Errorf("%q: %q, %v", modo, got, err)
+		}
+	}
+	for _, modo := range []string{"", "pausar", "Abortar", "parar"} {
+		if _, err := parseAveria(modo); err == nil {
+			t.Errorf("%q aceptado", modo)
+		}
+	}
+}
+
+// Plazos por categoría (en minúsculas y con espacios también); una categoría
+// desconocida, un plazo no positivo o un par mal escrito son errores.
+func TestParsePlazos(t *testing.T) {
+	got, err := parsePlazos("A=2m, b=1m30s,C=30s")
+	want := map[string]time.Duration{CatA: 2 * time.Minute, CatB: 90 * time.Second, CatC: 30 * time.Second}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("%v, %v", got, err)
+	}
+	for _, s := range []string{"", "A", "A=2", "D=1m", "A=-1s", "B=0s", "A=1m,,B=1m"} {
+		if got, err := parsePlazos(s); err == nil {
+			t.Errorf("%q aceptado: %v", s, got)
+		}
+	}
+}
+
+// Perfiles por recurso: "/" separa workers, "*" es un generalista y el
+// multiplicador es 1 si no se da. Lo mal escrito es un error.
+func TestParsePerfiles(t *testing.T) {
+	got, err := parsePerfiles("mecanicos=A/a/A,B:1.2; Limpieza=C:0.5/*")
+	want := map[string][]Perfil{
+		RecursoMecanicos: {{CatA: 1}, {CatA: 1}, {CatA: 1, CatB: 1.2}},
+		RecursoLimpieza:  {{CatC: 0.5}, nil},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("%v, %v", got, err)
+	}
+	for _, s := range []string{"", "mecanicos", "mecanicos=", "mecanicos=D", "mecanicos=A:0", "mecanicos=A:-1",
+		"mecanicos=A:x", "mecanicos=A//B", "mecanicos=A;mecanicos=B", "mecanicos=A;;limpieza=B"} {
+		if got, err := parsePerfiles(s); err == nil {
+			t.Errorf("%q aceptado: %v", s, got)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 )
@@ -19,6 +20,62 @@ type Config struct {
 	CapQ1 int
 	CapQ2 int
 	CapQ3 int
+
+	// Autoescalado de workers (desactivado por defecto: config estática).
+	Autoescalado AutoscaleConfig
+
+	// Perfiles (habilidades) de los workers por recurso: cada worker, al
+	// arrancar, usa el primero que no tenga otro worker vivo. Los que no
+	// tienen perfil son generalistas. Si entre los workers con que arranca
+	// un recurso ninguno sabe atender una categoría, no se arranca.
+	Perfiles map[string][]Perfil
+
+	// Horario de apertura del taller y turnos de los workers (reloj de simulación).
+	Calendario CalendarConfig
+
+	// Averías aleatorias de los workers (desactivadas si no hay MTBF).
+	Averias AveriasConfig
+
+	// Inspecciones de calidad tras una fase (clave: FaseMecanico o FaseLimpieza).
+	Inspecciones map[int]Inspeccion
+
+	// Repuestos que gasta la fase de mecánico (sin Consumo, no se controlan).
+	Inventario InventarioConfig
+
+	// Costes de mano de obra y de recursos parados, y margen que se cobra
+	// (sin tarifas, no se factura).
+	Costes CostesConfig
+
+	// Modo expropiativo: un coche urgente o un cambio a SOLO X interrumpen al
+	// coche en servicio, que vuelve a su cola con el trabajo que le falta.
+	Expropiativo bool
+
+	// Qué hace cada fase (0..3) con el coche en servicio si el estado pasa a
+	// cerrado, inactivo o SOLO de otra categoría: EnServicioTerminar (por
+	// defecto), EnServicioPausar o EnServicioAbortar.
+	EnServicio map[int]string
+
+	// Plazo de entrega prometido por categoría, contado desde la llegada
+	// (categoría sin plazo = sin compromiso de entrega).
+	Plazos map[string]time.Duration
+
+	// Colas EDF: se atiende primero el plazo más cercano en lugar de A->B->C.
+	ColaEDF bool
+
+	// Margen de sobreventa de la agenda de citas: 0.25 acepta reservas hasta
+	// un 25% por encima de la capacidad de plazas y mecánicos.
+	Sobreventa float64
+
+	// Checkpoints: cada CheckpointCada (0 = 5s) se guarda en el fichero
+	// Checkpoint una foto de la simulación. Con Restaurar, en lugar de generar
+	// coches se retoma la simulación de esa foto (capacidades incluidas).
+	Checkpoint     string
+	CheckpointCada time.Duration
+	Restaurar      *Checkpoint
+
+	// Traza de llegadas. Si no está vacía sustituye a NumA/NumB/NumC: cada
+	// coche entra en fase 0 en su instante de llegada y con su plazo.
+	Llegadas []Llegada
 }
 
 // DefaultConfig para ejecución manual (go run ./taller).
@@ -35,38 +92,455 @@ func DefaultConfig() Config {
 	}
 }
 
+// Nombres de los recursos tal y como se exponen hacia fuera (API de control).
+const (
+	RecursoPlazas    = "plazas"
+	RecursoMecanicos = "mecanicos"
+	RecursoLimpieza  = "limpieza"
+	RecursoEntrega   = "entrega"
+)
+
+// Simulation agrupa las colas y recursos de una simulación en marcha para
+// poder consultarla y modificarla desde fuera (API HTTP, tests...).
+// Los datos mutables (siguiente ID, nº de workers) los posee la goroutine loop.
+type Simulation struct {
+	start time.Time
+	logs  chan<- LogEvent
+	cfg   Config
+	cal   Calendario
+
+	plazas    *ResourcePool
+	mecanicos *ResourcePool
+	limpieza  *ResourcePool
+	entrega   *ResourcePool
+
+	q1 *PhaseQueue
+	q2 *PhaseQueue
+	q3 *PhaseQueue
+
+	inventario *Inventario // nil = sin control de piezas
+
+	reg *Registro // dónde está cada coche (para los checkpoints)
+
+	inspectores map[int]*inspector // por fase (la que inspecciona al terminar)
+
+	vida *vida // todas sus goroutines, para Stop
+
+	newCar    chan newCarReq
+	resize    chan resizeReq
+	ausencias chan int
+	foto      chan chan fotoLoop
+	retirados chan hueco
+
+	reservar  chan reservaReq
+	reservasQ chan chan []Reserva
+}
+
+type newCarReq struct {
+	categoria string
+	urgente   bool
+	plazo     time.Duration // desde la llegada; 0 = el de la categoría
+	traza     bool          // llegada de cfg.Llegadas
+	reply     chan Coche
+}
+
+// fotoLoop son los datos del loop que van en un checkpoint. Los coches
+// (del Registro) se piden desde el loop, que es quien da los IDs: así
+// ninguno queda fuera de la foto ni por debajo de siguienteID.
+type fotoLoop struct {
+	siguienteID int
+	llegadas    int // de la traza, ya dadas de alta
+	capacidades map[string]int
+	coches      map[int]Ubicacion
+}
+
+type resizeReq struct {
+	recurso string
+	n       int
+	reply   chan error
+}
+
 // startSimulation genera coches y los hace pasar por 4 fases.
 // Cada fase usa: cola con prioridad + recurso limitado (semáforo).
-func startSimulation(start time.Time, logs chan<- LogEvent, cfg Config) {
-	// Recursos físicos.
-	plazas := make(chan struct{}, cfg.NumPlazas)
-	mecanicos := make(chan struct{}, cfg.NumMecanicos)
-	limpieza := make(chan struct{}, cfg.NumLimpieza)
-	entrega := make(chan struct{}, cfg.NumEntrega)
-
-	// Colas por fase con capacidad máxima.
-	q1 := NewPhaseQueue(cfg.CapQ1)
-	q2 := NewPhaseQueue(cfg.CapQ2)
-	q3 := NewPhaseQueue(cfg.CapQ3)
+// Con cfg.Restaurar, start debe ser el arranque original (ahora - cp.Tiempo)
+// para que el reloj de simulación siga donde iba.
+func startSimulation(start time.Time, logs chan<- LogEvent, cfg Config) (*Simulation, error) {
+	if cp := cfg.Restaurar; cp != nil {
+		cfg.NumPlazas = cp.Capacidades[RecursoPlazas]
+		cfg.NumMecanicos = cp.Capacidades[RecursoMecanicos]
+		cfg.NumLimpieza = cp.Capacidades[RecursoLimpieza]
+		cfg.NumEntrega = cp.Capacidades[RecursoEntrega]
+	}
 
-	// Workers por fase.
-	for i := 0; i < cfg.NumMecanicos; i++ {
-		go phase1Worker(start, q1, q2, mecanicos, logs)
+	// Los perfiles y las inspecciones se comprueban antes de arrancar nada.
+	if err := comprobarPerfiles(cfg.Perfiles, map[string]int{
+		RecursoMecanicos: cfg.NumMecanicos,
+		RecursoLimpieza:  cfg.NumLimpieza,
+		RecursoEntrega:   cfg.NumEntrega,
+	}); err != nil {
+		return nil, err
 	}
-	for i := 0; i < cfg.NumLimpieza; i++ {
-		go phase2Worker(start, q2, q3, limpieza, logs)
+	var s *Simulation
+	inspectores := map[int]*inspector{}
+	for fase, insp := range cfg.Inspecciones {
+		in, err := newInspector(insp, fase, func(c Coche) { s.devolver(insp.VuelveA, c) })
+		if err != nil {
+			return nil, err
+		}
+		inspectores[fase] = in
 	}
-	for i := 0; i < cfg.NumEntrega; i++ {
-		go phase3Worker(start, q3, entrega, logs)
+
+	v := nuevaVida()
+	s = &Simulation{
+		start: start,
+		logs:  logs,
+		cfg:   cfg,
+		cal:   Calendario{start: start, cfg: cfg.Calendario},
+		vida:  v,
+
+		// Recursos físicos.
+		plazas:    newResourcePool(cfg.NumPlazas, v),
+		mecanicos: newResourcePool(cfg.NumMecanicos, v),
+		limpieza:  newResourcePool(cfg.NumLimpieza, v),
+		entrega:   newResourcePool(cfg.NumEntrega, v),
+
+		// Colas por fase con capacidad máxima.
+		q1: newPhaseQueue(cfg.CapQ1, cfg.ColaEDF, FaseMecanico, v),
+		q2: newPhaseQueue(cfg.CapQ2, cfg.ColaEDF, FaseLimpieza, v),
+		q3: newPhaseQueue(cfg.CapQ3, cfg.ColaEDF, FaseEntrega, v),
+
+		newCar:    make(chan newCarReq),
+		resize:    make(chan resizeReq),
+		ausencias: make(chan int),
+		foto:      make(chan chan fotoLoop),
+		retirados: make(chan hueco),
+
+		reservar:  make(chan reservaReq),
+		reservasQ: make(chan chan []Reserva),
+
+		reg:         newRegistro(v),
+		inspectores: inspectores,
+	}
+	// Cuando llegan piezas, los mecánicos que saltan coches vuelven a mirar la cola.
+	s.inventario = newInventario(cfg.Inventario, start, logs, func() { v.lanzar(s.q1.Reintentar) }, v)
+
+	// Generamos coches por categoría (A/B/C) y orden aleatorio, todos
+	// llegan al arrancar. Con traza de llegadas, los mete inyectarLlegadas.
+	// Al restaurar, los coches son los del checkpoint, y de la traza solo
+	// faltan los que aún no habían llegado.
+	var coches []Coche
+	nextID, llegadas := 1, 0
+	switch {
+	case cfg.Restaurar != nil:
+		nextID, llegadas = cfg.Restaurar.SiguienteID, cfg.Restaurar.Llegadas
+		s.restaurar(cfg.Restaurar)
+	case len(cfg.Llegadas) == 0:
+		coches = genCoches(cfg.NumA, cfg.NumB, cfg.NumC)
+		for i := range coches {
+			coches[i].Plazo = cfg.Plazos[coches[i].Categoria]
+		}
+		nextID = len(coches) + 1
 	}
 
-	// Generamos coches por categoría (A/B/C) y orden aleatorio.
-	coches := genCoches(cfg.NumA, cfg.NumB, cfg.NumC)
+	v.lanzar(func() { s.loop(cfg, nextID, llegadas) })
+	v.lanzar(s.watchState)
+	v.lanzar(s.agendaLoop)
+	if cfg.Autoescalado.Activo {
+		v.lanzar(func() { s.autoscale(cfg) })
+	}
+	if cfg.Costes.activo() {
+		v.lanzar(s.medirOcioso)
+	}
+	if len(cfg.Llegadas) > 0 {
+		v.lanzar(func() { s.inyectarLlegadas(cfg.Llegadas, llegadas) })
+	}
+	if cfg.Checkpoint != "" {
+		cada := cfg.CheckpointCada
+		if cada <= 0 {
+			cada = 5 * time.Second
+		}
+		v.lanzar(func() { s.guardarCheckpoints(cfg.Checkpoint, cada) })
+	}
 
-	// Fase 0: un goroutine por coche.
+	// Fase 0: un goroutine por coche (apuntado ya en el Registro, para que
+	// un checkpoint no se lo pierda si llega antes que su goroutine).
 	for _, c := range coches {
 		coche := c
-		go fase0Plaza(start, coche, plazas, q1, logs)
+		s.reg.EnCola(coche, FaseEsperaPlaza)
+		v.lanzar(func() { fase0Plaza(start, s.plazaSpec(), coche, logs) })
+	}
+	return s, nil
+}
+
+// Stop para la simulación: termina todas sus goroutines (workers, coches,
+// colas, recursos...) y espera a que acaben. Los coches se quedan donde
+// estuvieran. Después no se puede usar la simulación.
+func (s *Simulation) Stop() {
+	s.vida.parar()
+}
+
+// plazaSpec reúne lo que necesita la fase 0 de esta simulación.
+func (s *Simulation) plazaSpec() plazaSpec {
+	return plazaSpec{
+		cal:        s.cal,
+		costes:     s.cfg.Costes,
+		enServicio: s.cfg.EnServicio[FaseEsperaPlaza],
+		plazas:     s.plazas,
+		q1:         s.q1,
+		reg:        s.reg,
+		vida:       s.vida,
+	}
+}
+
+// fotoLoop pide al loop lo que le toca del checkpoint.
+func (s *Simulation) fotoLoop() fotoLoop {
+	reply := make(chan fotoLoop, 1)
+	enviar(s.vida, s.foto, reply)
+	return recibir(s.vida, reply)
+}
+
+// loop lanza los workers iniciales y atiende altas de coches y cambios de recursos.
+func (s *Simulation) loop(cfg Config, nextID, llegadas int) {
+	// Capacidad pedida para cada recurso (Config o API) y mecánicos que se han
+	// ido a casa según el estado (códigos 10/11). La efectiva es la diferencia.
+	base := map[string]int{
+		RecursoPlazas:    cfg.NumPlazas,
+		RecursoMecanicos: cfg.NumMecanicos,
+		RecursoLimpieza:  cfg.NumLimpieza,
+		RecursoEntrega:   cfg.NumEntrega,
+	}
+	fuera := 0
+
+	// Un canal stop por worker vivo: cerrarlo retira a ese worker
+	// en cuanto termine el coche que tenga entre manos.
+	workers := map[string][]chan struct{}{}
+
+	// Los workers se numeran sin repetir nunca un número, y cada uno coge
+	// su hueco de Perfiles/Turnos al arrancar y lo suelta al acabar (por
+	// retirados), no al retirarlo.
+	numero := map[string]int{}
+	ocupados := huecos{}
+
+	efectiva := func(recurso string) int {
+		n := base[recurso]
+		if recurso == RecursoMecanicos {
+			n -= fuera
+		}
+		if n < 0 {
+			n = 0
+		}
+		return n
+	}
+
+	// Ajusta capacidad del recurso y nº de workers de su fase a la efectiva.
+	// Al encoger, el recurso no concede huecos nuevos hasta que los ocupados
+	// bajen de la capacidad, y los workers retirados acaban su coche actual.
+	apply := func(recurso string) {
+		n := efectiva(recurso)
+		s.pool(recurso).SetCapacity(n)
+
+		if recurso == RecursoPlazas {
+			return // fase 0 no tiene workers: un goroutine por coche
+		}
+		for len(workers[recurso]) > n {
+			last := len(workers[recurso]) - 1
+			close(workers[recurso][last])
+			workers[recurso] = workers[recurso][:last]
+		}
+		for len(workers[recurso]) < n {
+			stop := make(chan struct{})
+			workers[recurso] = append(workers[recurso], stop)
+			numero[recurso]++
+			s.spawnWorker(recurso, numero[recurso], ocupados.coger(recurso), stop)
+		}
+	}
+
+	// Workers por fase.
+	apply(RecursoMecanicos)
+	apply(RecursoLimpieza)
+	apply(RecursoEntrega)
+
+	for {
+		select {
+		case <-s.vida.parada():
+			return
+
+		case r := <-s.newCar:
+			c := Coche{ID: nextID, Categoria: r.categoria, Urgente: r.urgente}
+			nextID++
+			if r.traza {
+				llegadas++
+			}
+			plazo := r.plazo
+			if plazo == 0 {
+				plazo = cfg.Plazos[r.categoria]
+			}
+			if plazo > 0 {
+				c.Plazo = simSince(s.start) + plazo
+			}
+			// Al Registro antes de soltar el loop, para que una foto que
+			// ya cuenta su ID también lo tenga a él.
+			s.reg.EnCola(c, FaseEsperaPlaza)
+			s.vida.lanzar(func() { fase0Plaza(s.start, s.plazaSpec(), c, s.logs) })
+			r.reply <- c
+
+		case r := <-s.resize:
+			if s.pool(r.recurso) == nil {
+				r.reply <- fmt.Errorf("recurso desconocido: %q", r.recurso)
+				continue
+			}
+			if r.n < 0 {
+				r.reply <- fmt.Errorf("capacidad negativa para %s: %d", r.recurso, r.n)
+				continue
+			}
+			base[r.recurso] = r.n
+			apply(r.recurso)
+			r.reply <- nil
+
+		case n := <-s.ausencias:
+			fuera = n
+			apply(RecursoMecanicos)
+
+		case h := <-s.retirados:
+			ocupados.soltar(h)
+
+		case reply := <-s.foto:
+			capacidades := make(map[string]int, len(base))
+			for r, n := range base {
+				capacidades[r] = n
+			}
+			reply <- fotoLoop{siguienteID: nextID, llegadas: llegadas, capacidades: capacidades, coches: s.reg.Todos()}
+		}
+	}
+}
+
+// spawnWorker lanza el worker n (1, 2, ...) de la fase asociada al recurso,
+// con el perfil y el turno de su hueco. Al retirarse suelta el hueco.
+func (s *Simulation) spawnWorker(recurso string, n int, h hueco, stop <-chan struct{}) {
+	w := workerSpec{
+		recurso: recurso,
+		n:       n,
+		perfil:  perfilWorker(s.cfg.Perfiles, recurso, h.n),
+		cal:     s.cal,
+		turno:   s.cal.turnoWorker(recurso, h.n),
+		averia:  newAveriaWorker(s.cfg.Averias, recurso),
+		costes:  s.cfg.Costes,
+		stop:    stop,
+		vida:    s.vida,
+	}
+	w.expropiativo = s.cfg.Expropiativo
+	switch recurso {
+	case RecursoMecanicos:
+		w.fase, w.in, w.out, w.res = FaseMecanico, s.q1, s.q2, s.mecanicos
+		w.inventario = s.inventario
+	case RecursoLimpieza:
+		w.fase, w.in, w.out, w.res = FaseLimpieza, s.q2, s.q3, s.limpieza
+	case RecursoEntrega:
+		w.fase, w.in, w.out, w.res = FaseEntrega, s.q3, nil, s.entrega
+	default:
+		return
+	}
+	w.inspector = s.inspectores[w.fase]
+	w.enServicio = s.cfg.EnServicio[w.fase]
+	w.reg = s.reg
+	s.vida.lanzar(func() {
+		phaseWorker(s.start, w, s.logs)
+		enviar(s.vida, s.retirados, h)
+	})
+}
+
+// devolver mete en la fase dada un coche que no ha pasado la inspección,
+// sin bloquear al worker: en fase 0 vuelve a esperar plaza y en las demás va
+// al principio de su cola, sin esperar hueco (ya estaba admitido; esperarlo
+// podría bloquear dos fases llenas que se esperan la una a la otra).
+func (s *Simulation) devolver(fase int, c Coche) {
+	switch fase {
+	case FaseEsperaPlaza:
+		s.vida.lanzar(func() { fase0Plaza(s.start, s.plazaSpec(), c, s.logs) })
+	case FaseMecanico:
+		s.q1.EnqueueFront(c)
+	case FaseLimpieza:
+		s.q2.EnqueueFront(c)
+	}
+}
+
+// watchState vigila los mecánicos ausentes del estado (códigos 10/11)
+// y avisa al loop cuando cambian.
+func (s *Simulation) watchState() {
+	last := 0
+	for {
+		st := stateProvider()
+		if st.MecanicosFuera != last {
+			last = st.MecanicosFuera
+			enviar(s.vida, s.ausencias, last)
+		}
+		s.vida.dormir(200 * time.Millisecond)
+	}
+}
+
+// pool devuelve el recurso por nombre (nil si no existe).
+func (s *Simulation) pool(recurso string) *ResourcePool {
+	switch recurso {
+	case RecursoPlazas:
+		return s.plazas
+	case RecursoMecanicos:
+		return s.mecanicos
+	case RecursoLimpieza:
+		return s.limpieza
+	case RecursoEntrega:
+		return s.entrega
+	}
+	return nil
+}
+
+// AddCoche da de alta un coche nuevo de la categoría indicada y lo mete en fase 0.
+// El plazo de entrega es el de su categoría (Config.Plazos).
+func (s *Simulation) AddCoche(categoria string) Coche {
+	return s.addCoche(categoria, 0, false)
+}
+
+// AddCocheUrgente es AddCoche para un coche urgente: sale de las colas antes
+// que los demás y, en modo expropiativo, puede interrumpir a otro en servicio.
+func (s *Simulation) AddCocheUrgente(categoria string) Coche {
+	return s.addCoche(categoria, 0, true)
+}
+
+// addCoche es AddCoche con un plazo concreto (0 = el de la categoría).
+func (s *Simulation) addCoche(categoria string, plazo time.Duration, urgente bool) Coche {
+	return s.alta(newCarReq{categoria: categoria, plazo: plazo, urgente: urgente})
+}
+
+// alta pide al loop que dé de alta el coche.
+func (s *Simulation) alta(r newCarReq) Coche {
+	r.reply = make(chan Coche, 1)
+	enviar(s.vida, s.newCar, r)
+	return recibir(s.vida, r.reply)
+}
+
+// Resize cambia la capacidad de un recurso y ajusta los workers de su fase.
+// Al encoger no interrumpe trabajos: los sobrantes se retiran al acabar su coche.
+func (s *Simulation) Resize(recurso string, n int) error {
+	reply := make(chan error, 1)
+	enviar(s.vida, s.resize, resizeReq{recurso: recurso, n: n, reply: reply})
+	return recibir(s.vida, reply)
+}
+
+// Recursos devuelve una foto de todos los recursos físicos.
+func (s *Simulation) Recursos() map[string]PoolSnapshot {
+	return map[string]PoolSnapshot{
+		RecursoPlazas:    s.plazas.Snapshot(),
+		RecursoMecanicos: s.mecanicos.Snapshot(),
+		RecursoLimpieza:  s.limpieza.Snapshot(),
+		RecursoEntrega:   s.entrega.Snapshot(),
+	}
+}
+
+// Colas devuelve una foto de las colas de las fases 1, 2 y 3.
+func (s *Simulation) Colas() map[string]QueueSnapshot {
+	return map[string]QueueSnapshot{
+		"fase1": s.q1.Snapshot(),
+		"fase2": s.q2.Snapshot(),
+		"fase3": s.q3.Snapshot(),
 	}
 }
 
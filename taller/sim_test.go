@@ -22,25 +22,59 @@ type cfgCase struct {
 // (Si quieres aún más rápido, sube a 50).
 const timeScale = 20
 
-func scaledSleep(d time.Duration) {
-	if d <= 0 {
-		return
+func scaledSleep(d time.Duration) <-chan time.Time {
+	return time.After(d / timeScale)
+}
+
+// acelerar fija el estado y acelera el reloj de la simulación durante el
+// test; al acabar vuelven los de antes. Las simulaciones de arrancar se
+// paran antes (t.Cleanup va al revés), así que nadie los lee ya.
+func acelerar(t *testing.T, estado func() TallerState) {
+	t.Helper()
+	prov, sleep, since := stateProvider, sleepFn, simSince
+	stateProvider = estado
+	sleepFn = scaledSleep
+	simSince = func(t time.Time) time.Duration { return time.Since(t) * timeScale }
+	t.Cleanup(func() { stateProvider, sleepFn, simSince = prov, sleep, since })
+}
+
+// arrancar es startSimulation con la simulación parada al acabar el test.
+// Después cierra logs: quien lo lea con range termina.
+func arrancar(t *testing.T, start time.Time, logs chan LogEvent, cfg Config) *Simulation {
+	t.Helper()
+	s, err := startSimulation(start, logs, cfg)
+	if err != nil {
+		t.Fatal(err)
 	}
-	time.Sleep(d / timeScale)
+	t.Cleanup(func() {
+		// Mientras se para, que ningún worker se quede bloqueado en logs.
+		go func() {
+			for range logs {
+			}
+		}()
+		s.Stop()
+		close(logs)
+	})
+	return s
 }
 
 // runScenario ejecuta una simulación SIN TCP ni servidor.
 // Devuelve duración total y throughput.
 func runScenario(t *testing.T, cfg Config) (time.Duration, float64) {
 	t.Helper()
+	dur, th, _ := runScenarioInforme(t, cfg)
+	return dur, th
+}
 
-	// Estado fijo NORMAL durante el test.
-	stateProvider = func() TallerState {
-		return TallerState{Activo: true, Cerrado: false}
-	}
+// runScenarioInforme es runScenario devolviendo además el Informe de la ejecución.
+func runScenarioInforme(t *testing.T, cfg Config) (time.Duration, float64, *Informe) {
+	t.Helper()
 
-	// Sleep acelerado (para que no peten los timeouts).
-	sleepFn = scaledSleep
+	// Estado fijo NORMAL durante el test y sleep acelerado (para que no
+	// peten los timeouts).
+	acelerar(t, func() TallerState {
+		return TallerState{Activo: true, Cerrado: false}
+	})
 
 	logCh := make(chan LogEvent, 8192)
 
@@ -49,9 +83,11 @@ func runScenario(t *testing.T, cfg Config) (time.Duration, float64) {
 
 	start := time.Now()
 
+	inf := NewInforme()
 	done := make(chan struct{})
 	go func() {
 		for ev := range logCh {
+			inf.Observar(ev)
 			if ev.Fase == FaseEntrega && ev.Estado == "Sale" {
 				if atomic.AddInt32(&finished, 1) == totalCoches {
 					close(done)
@@ -61,20 +97,19 @@ func runScenario(t *testing.T, cfg Config) (time.Duration, float64) {
 		}
 	}()
 
-	startSimulation(start, logCh, cfg)
+	arrancar(t, start, logCh, cfg)
 
 	// Timeout del escenario (ya no debería saltar con timeScale).
 	select {
 	case <-done:
-		// NO cerramos logCh para evitar que goroutines antiguas de otros subtests hagan send a canal cerrado.
-		// (Los workers se quedan vivos, pero en tests no nos afecta y evitamos panics.)
+		// La simulación (y con ella todos los workers) se para al acabar el test.
 	case <-time.After(2 * time.Minute):
 		t.Fatalf("timeout: finalizaron %d/%d coches", finished, totalCoches)
 	}
 
 	dur := time.Since(start)
 	throughput := float64(totalCoches) / dur.Seconds()
-	return dur, throughput
+	return dur, throughput, inf
 }
 
 func TestComparativas_6Casos(t *testing.T) {
@@ -113,3 +148,611 @@ func TestComparativas_6Casos(t *testing.T) {
 		}
 	}
 }
+
+// Misma comparativa de distribuciones, pero con mecánicos elásticos:
+// se arranca con 1 y el autoescalado sube hasta 4 según la cola de fase 1.
+func TestComparativas_Autoescalado(t *testing.T) {
+	tests := []testCase{
+		{name: "T1_10_10_10", numA: 10, numB: 10, numC: 10},
+		{name: "T2_20_5_5", numA: 20, numB: 5, numC: 5},
+		{name: "T3_5_5_20", numA: 5, numB: 5, numC: 20},
+	}
+
+	for _, tc := range tests {
+		name := fmt.Sprintf("%s_AUTO_P6_M1-4", tc.name)
+
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+
+			cfg.NumA, cfg.NumB, cfg.NumC = tc.numA, tc.numB, tc.numC
+			cfg.NumPlazas = 6
+			cfg.NumMecanicos = 1
+			cfg.NumLimpieza = 1
+			cfg.NumEntrega = 1
+			cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+
+			cfg.Autoescalado = DefaultAutoscale()
+			cfg.Autoescalado.Min = map[string]int{RecursoMecanicos: 1}
+			cfg.Autoescalado.Max = map[string]int{RecursoMecanicos: 4}
+
+			dur, th, inf := runScenarioInforme(t, cfg)
+			if inf.Escalados == 0 {
+				t.Error("con 1 mecánico y la cola llena, el autoescalado no ha hecho nada")
+			}
+			t.Logf("%s (timeScale=%dx) -> dur=%v | throughput=%.2f coches/s | %d escalados", name, timeScale, dur, th, inf.Escalados)
+		})
+	}
+}
+
+// Si la API cambia los mecánicos, el autoescalado parte de lo que ha puesto
+// la API y no de lo último que puso él: cada decisión sale de la capacidad
+// que dejó la anterior (o la API).
+func TestAutoescalado_TrasResize(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	cfg := DefaultConfig()
+	cfg.NumA, cfg.NumB, cfg.NumC = 10, 10, 10
+	cfg.NumPlazas, cfg.NumMecanicos = 6, 1
+	cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+	cfg.Autoescalado = DefaultAutoscale()
+	cfg.Autoescalado.Min = map[string]int{RecursoMecanicos: 1}
+	cfg.Autoescalado.Max = map[string]int{RecursoMecanicos: 4}
+
+	logCh := make(chan LogEvent, 8192)
+	s := arrancar(t, time.Now(), logCh, cfg)
+	if err := s.Resize(RecursoMecanicos, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, escalados, entregados := 4, 0, 0
+	timeout := time.After(2 * time.Minute)
+	for entregados < 30 {
+		select {
+		case ev := <-logCh:
+			switch {
+			case ev.Fase == FaseEntrega && ev.Estado == EstadoSale:
+				entregados++
+			case ev.Estado == EstadoEscalado:
+				var motivo, recurso string
+				var de, a int
+				if _, err := fmt.Sscanf(ev.Detalle, "%s %s %d->%d", &motivo, &recurso, &de, &a); err != nil {
+					t.Fatalf("%q: %v", ev.Detalle, err)
+				}
+				if de != actual {
+					t.Fatalf("%q: parte de %d, había %d", ev.Detalle, de, actual)
+				}
+				actual = a
+				escalados++
+			}
+		case <-timeout:
+			t.Fatalf("timeout: %d/30 entregados", entregados)
+		}
+	}
+	if escalados == 0 {
+		t.Error("el autoescalado no ha hecho nada")
+	}
+}
+
+// Limpieza está en Max pero no en Min: sin coches, el autoescalado la baja
+// de 3 a 1 y ahí se queda, en vez de retirar al último y parar la fase.
+func TestAutoescalado_MinPorDefecto(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	cfg := DefaultConfig()
+	cfg.NumA, cfg.NumB, cfg.NumC = 0, 0, 0
+	cfg.NumLimpieza = 3
+	cfg.Autoescalado = DefaultAutoscale()
+	cfg.Autoescalado.Cooldown = time.Second
+	cfg.Autoescalado.Min = map[string]int{RecursoMecanicos: 1}
+	cfg.Autoescalado.Max = map[string]int{RecursoLimpieza: 3}
+
+	logCh := make(chan LogEvent, 1024)
+	s := arrancar(t, time.Now(), logCh, cfg)
+
+	// 30 s de simulación: tiempo para muchas más bajadas de las que caben.
+	limite := time.After(30 * time.Second / timeScale)
+	var bajadas []string
+	for esperando := true; esperando; {
+		select {
+		case ev := <-logCh:
+			if ev.Estado == EstadoEscalado {
+				bajadas = append(bajadas, ev.Detalle)
+			}
+		case <-limite:
+			esperando = false
+		}
+	}
+	if len(bajadas) != 2 {
+		t.Fatalf("bajadas %q, quería 3->2 y 2->1", bajadas)
+	}
+	if n := s.Recursos()[RecursoLimpieza].Capacidad; n != 1 {
+		t.Fatalf("limpieza con %d workers, quería 1", n)
+	}
+}
+
+// Un recurso cuyos workers no saben atender alguna categoría no arranca;
+// si hay más workers que perfiles, los que sobran son generalistas.
+func TestPerfiles_Cobertura(t *testing.T) {
+	soloA := []Perfil{{CatA: 1}, {CatA: 1, CatB: 1}, {CatC: 1}}
+	for _, tc := range []struct {
+		workers int
+		ok      bool
+	}{
+		{2, false}, // nadie hace C
+		{3, true},
+		{4, true}, // el cuarto es generalista
+		{0, true}, // sin workers no hay nada que comprobar
+	} {
+		err := comprobarPerfiles(map[string][]Perfil{RecursoMecanicos: soloA}, map[string]int{RecursoMecanicos: tc.workers})
+		if (err == nil) != tc.ok {
+			t.Errorf("%d workers: %v", tc.workers, err)
+		}
+	}
+	if err := comprobarPerfiles(map[string][]Perfil{RecursoPlazas: {nil}}, map[string]int{RecursoMecanicos: 1}); err == nil {
+		t.Error("perfiles de plazas aceptados")
+	}
+
+	cfg := DefaultConfig()
+	cfg.NumMecanicos = 2
+	cfg.Perfiles = map[string][]Perfil{RecursoMecanicos: soloA}
+	if s, err := startSimulation(time.Now(), make(chan LogEvent, 1), cfg); err == nil {
+		s.Stop()
+		t.Fatal("arranca con la categoría C sin mecánico")
+	}
+}
+
+// El tiempo de servicio de un worker es el de la categoría (±20 %) por el
+// multiplicador de su perfil.
+func TestPerfiles_Multiplicador(t *testing.T) {
+	for _, mult := range []float64{3, 0.5} {
+		t.Run(fmt.Sprint(mult), func(t *testing.T) {
+			acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+			cfg := DefaultConfig()
+			cfg.NumA, cfg.NumB, cfg.NumC = 3, 0, 0
+			cfg.NumMecanicos = 1
+			cfg.Perfiles = map[string][]Perfil{RecursoMecanicos: {{CatA: mult, CatB: 1, CatC: 1}}}
+
+			logCh := make(chan LogEvent, 1024)
+			arrancar(t, time.Now(), logCh, cfg)
+
+			// ±20% de jitter y holgura para el planificador: con el tiempo
+			// acelerado, cada ms de retraso real son timeScale ms simulados.
+			// Un coche sin multiplicar (de 4 a 6 s) queda fuera de ambos rangos.
+			base := categoriaBaseDur(CatA).Seconds() * mult
+			lo, hi := 0.8*base*0.9, 1.2*base*1.1+0.5
+			entra := map[int]time.Duration{}
+			timeout := time.After(time.Minute)
+			for servidos := 0; servidos < cfg.NumA; {
+				select {
+				case ev := <-logCh:
+					if ev.Fase != FaseMecanico {
+						continue
+					}
+					switch ev.Estado {
+					case EstadoEntra:
+						entra[ev.CocheID] = ev.Elapsed
+					case EstadoSale:
+						d := (ev.Elapsed - entra[ev.CocheID]).Seconds()
+						if d < lo || d > hi {
+							t.Errorf("coche %d: %.2fs en el mecánico, quería entre %.2f y %.2f", ev.CocheID, d, lo, hi)
+						}
+						servidos++
+					}
+				case <-timeout:
+					t.Fatal("timeout")
+				}
+			}
+		})
+	}
+}
+
+// Con averías frecuentes, tanto pausando como abortando, todos los coches
+// deben acabar entregados (ninguno se pierde al volver a la cola).
+func TestAverias_TodosLosCochesTerminan(t *testing.T) {
+	for _, modo := range []string{AveriaPausa, AveriaAbortar} {
+		t.Run(modo, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.NumA, cfg.NumB, cfg.NumC = 5, 5, 5
+			cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+
+			cfg.Averias = AveriasConfig{
+				MTBF: map[string]time.Duration{RecursoMecanicos: 4 * time.Second, RecursoLimpieza: 4 * time.Second},
+				MTTR: map[string]time.Duration{RecursoMecanicos: 1 * time.Second, RecursoLimpieza: 1 * time.Second},
+				Modo: modo,
+			}
+
+			dur, th, inf := runScenarioInforme(t, cfg)
+			if inf.Averias == 0 {
+				t.Error("con MTBF de 4s no se ha averiado nadie")
+			}
+			t.Logf("averías %s (timeScale=%dx) -> dur=%v | throughput=%.2f coches/s | %d averías", modo, timeScale, dur, th, inf.Averias)
+		})
+	}
+}
+
+// Con inspección tras limpieza, los coches que no pasan vuelven a mecánico
+// (o a esperar plaza) como mucho MaxRetrabajos veces y todos acaban entregados.
+func TestInspeccion_Retrabajos(t *testing.T) {
+	for _, vuelveA := range []int{FaseMecanico, FaseEsperaPlaza} {
+		t.Run(fmt.Sprintf("vuelveA=%d", vuelveA), func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.NumA, cfg.NumB, cfg.NumC = 3, 3, 6
+			cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+			cfg.Inspecciones = map[int]Inspeccion{
+				FaseLimpieza: {
+					VuelveA:       vuelveA,
+					ProbFallo:     map[string]float64{CatA: 0.5, CatB: 0.5, CatC: 0.5},
+					MaxRetrabajos: 2,
+				},
+			}
+
+			_, _, inf := runScenarioInforme(t, cfg)
+			if inf.Retrabajos == 0 {
+				t.Error("con un 50% de fallos no ha vuelto atrás ningún coche")
+			}
+			for id, n := range inf.RetrabajosPorCoche {
+				if n > 2 {
+					t.Errorf("coche %d: %d retrabajos (máx 2)", id, n)
+				}
+			}
+			t.Log(inf)
+		})
+	}
+}
+
+// Con pocas piezas y reposición lenta, todos los coches acaban saliendo,
+// tanto esperando las piezas como saltando a otro coche.
+func TestInventario_EsperarVsSaltar(t *testing.T) {
+	for _, saltar := range []bool{false, true} {
+		t.Run(fmt.Sprintf("saltar=%v", saltar), func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+			cfg.Inventario = DefaultInventario(10 * time.Second)
+			cfg.Inventario.Saltar = saltar
+
+			_, _, inf := runScenarioInforme(t, cfg)
+			if len(inf.Agotados) == 0 {
+				t.Error("esperaba alguna rotura de stock")
+			}
+			t.Log(inf)
+		})
+	}
+}
+
+// Comparativa económica entre plantillas: el coste de cada paso por una
+// fase sale de su tarifa y del tiempo entre su Entra y su Sale, lo facturado
+// es la suma de esos costes más el margen, y los recursos parados cuestan.
+func TestCostes_Mecanicos(t *testing.T) {
+	for _, mecanicos := range []int{2, 4} {
+		t.Run(fmt.Sprintf("mecanicos=%d", mecanicos), func(t *testing.T) {
+			acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+			cfg := DefaultConfig()
+			cfg.NumMecanicos = mecanicos
+			cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+			cfg.Costes = DefaultCostes()
+			cfg.Costes.Muestreo = time.Second
+
+			logCh := make(chan LogEvent, 8192)
+			arrancar(t, time.Now(), logCh, cfg)
+
+			categoria := map[string]string{}
+			for _, cat := range []string{CatA, CatB, CatC} {
+				categoria[categoriaTipo(cat)] = cat
+			}
+			entra := map[[2]int]time.Duration{} // coche, fase -> Entra
+			acumulado := map[int]float64{}      // coche -> coste de sus fases
+			facturado := map[string]float64{}   // incidencia -> lo que debería cobrarse
+
+			inf := NewInforme()
+			total := cfg.NumA + cfg.NumB + cfg.NumC
+			timeout := time.After(2 * time.Minute)
+			for entregados := 0; entregados < total; {
+				select {
+				case ev := <-logCh:
+					inf.Observar(ev)
+					switch ev.Estado {
+					case EstadoEntra:
+						entra[[2]int{ev.CocheID, ev.Fase}] = ev.Elapsed
+					case EstadoSale:
+						d := ev.Elapsed - entra[[2]int{ev.CocheID, ev.Fase}]
+						want := cfg.Costes.coste(ev.Fase, categoria[ev.Incidencia], d)
+						if !casiIgual(ev.Coste, want) {
+							t.Errorf("coche %d fase %d (%v): coste %.2f, quería %.2f", ev.CocheID, ev.Fase, d, ev.Coste, want)
+						}
+						acumulado[ev.CocheID] += want
+						if ev.Fase == FaseEntrega {
+							facturado[ev.Incidencia] += acumulado[ev.CocheID] * 1.3
+							entregados++
+						}
+					}
+				case <-timeout:
+					t.Fatal("timeout esperando las entregas")
+				}
+			}
+
+			ocioso := 0.0
+			for _, c := range inf.Ocioso {
+				ocioso += c
+			}
+			for _, inc := range incidencias() {
+				if !casiIgual(inf.Facturado[inc], facturado[inc]) {
+					t.Errorf("%s: facturado %.2f, quería %.2f", inc, inf.Facturado[inc], facturado[inc])
+				}
+			}
+			if ocioso <= 0 {
+				t.Error("esperaba coste de recursos parados")
+			}
+			t.Log(inf)
+		})
+	}
+}
+
+// Con un solo mecánico ocupado con un A, un coche urgente lo expropia: sale
+// antes del mecánico y el A se retoma después con lo que le faltaba.
+func TestExpropiacion_Urgente(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	cfg := DefaultConfig()
+	cfg.NumA, cfg.NumB, cfg.NumC = 1, 0, 0
+	cfg.NumMecanicos = 1
+	cfg.Expropiativo = true
+
+	logCh := make(chan LogEvent, 1024)
+	s := arrancar(t, time.Now(), logCh, cfg)
+
+	var urgente Coche
+	var orden []string // eventos de fase 1 por orden
+	timeout := time.After(time.Minute)
+	for len(orden) < 5 {
+		select {
+		case ev := <-logCh:
+			if ev.Fase != FaseMecanico || ev.CocheID == 0 {
+				continue
+			}
+			if ev.Estado == EstadoEntra && ev.CocheID == 1 && urgente.ID == 0 {
+				urgente = s.AddCocheUrgente(CatC)
+			}
+			orden = append(orden, fmt.Sprintf("%d %s", ev.CocheID, ev.Estado))
+		case <-timeout:
+			t.Fatalf("timeout, eventos de fase 1: %v", orden)
+		}
+	}
+
+	// Entra A, A expropiado, entra urgente, sale urgente, entra A (reanuda después).
+	want := []string{"1 Entra", "1 Expropiado", fmt.Sprintf("%d Entra", urgente.ID), fmt.Sprintf("%d Sale", urgente.ID), "1 Entra"}
+	if fmt.Sprint(orden) != fmt.Sprint(want) {
+		t.Fatalf("eventos de fase 1 = %v, want %v", orden, want)
+	}
+}
+
+// Al cerrar con un coche a medias en el mecánico, con "pausar" se para y
+// sigue al reabrir; con "abortar" vuelve a la cola y empieza de nuevo.
+func TestEnServicio_CerradoCongela(t *testing.T) {
+	for _, politica := range []string{EnServicioPausar, EnServicioAbortar} {
+		t.Run(politica, func(t *testing.T) {
+			var cerrado atomic.Bool
+			acelerar(t, func() TallerState { return TallerState{Activo: !cerrado.Load(), Cerrado: cerrado.Load()} })
+
+			cfg := DefaultConfig()
+			cfg.NumA, cfg.NumB, cfg.NumC = 1, 0, 0
+			cfg.EnServicio = map[int]string{FaseMecanico: politica}
+
+			logCh := make(chan LogEvent, 1024)
+			arrancar(t, time.Now(), logCh, cfg)
+
+			var orden []string // eventos de fase 1 por orden
+			timeout := time.After(time.Minute)
+			for len(orden) == 0 || orden[len(orden)-1] != EstadoSale {
+				select {
+				case ev := <-logCh:
+					if ev.Fase != FaseMecanico {
+						continue
+					}
+					orden = append(orden, ev.Estado)
+					switch ev.Estado {
+					case EstadoEntra:
+						if len(orden) == 1 {
+							cerrado.Store(true)
+						}
+					case EstadoPausa, EstadoAbortado:
+						go func() {
+							<-scaledSleep(time.Second)
+							cerrado.Store(false)
+						}()
+					}
+				case <-timeout:
+					t.Fatalf("timeout, eventos de fase 1: %v", orden)
+				}
+			}
+
+			want := []string{EstadoEntra, EstadoPausa, EstadoReanuda, EstadoSale}
+			if politica == EnServicioAbortar {
+				want = []string{EstadoEntra, EstadoAbortado, EstadoEntra, EstadoSale}
+			}
+			if fmt.Sprint(orden) != fmt.Sprint(want) {
+				t.Fatalf("eventos de fase 1 = %v, want %v", orden, want)
+			}
+		})
+	}
+}
+
+// Una simulación restaurada de un checkpoint tomado a mitad entrega una vez
+// cada coche que la foto tenía vivo (y ninguno de los ya entregados), y los
+// que estaban en servicio solo hacen lo que les faltaba.
+func TestCheckpoint_Restaurar(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	cfg := DefaultConfig()
+	cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+
+	// La primera se para tras la foto, como si el proceso muriera.
+	primeraLogs := make(chan LogEvent, 8192)
+	primera := arrancar(t, time.Now(), primeraLogs, cfg)
+	<-scaledSleep(10 * time.Second)
+	cp := primera.Checkpoint()
+	primera.Stop()
+	if len(cp.Coches) == 0 {
+		t.Fatal("checkpoint sin coches")
+	}
+
+	entregados := map[int]bool{}
+	for len(primeraLogs) > 0 {
+		if ev := <-primeraLogs; ev.Fase == FaseEntrega && ev.Estado == EstadoSale {
+			entregados[ev.CocheID] = true
+		}
+	}
+
+	// Entre los vivos y los entregados están todos, y una sola vez.
+	vivos := map[int]Ubicacion{}
+	for _, u := range cp.Coches {
+		if entregados[u.Coche.ID] || vivos[u.Coche.ID].Coche.ID != 0 {
+			t.Fatalf("coche %d dos veces en la foto o ya entregado", u.Coche.ID)
+		}
+		if u.EnServicio && u.Coche.Resto <= 0 {
+			t.Fatalf("coche %d en servicio sin resto: %+v", u.Coche.ID, u)
+		}
+		vivos[u.Coche.ID] = u
+	}
+	total := cfg.NumA + cfg.NumB + cfg.NumC
+	if len(vivos)+len(entregados) != total || cp.SiguienteID != total+1 {
+		t.Fatalf("%d vivos + %d entregados (siguiente ID %d), quería %d", len(vivos), len(entregados), cp.SiguienteID, total)
+	}
+
+	logCh := make(chan LogEvent, 8192)
+	cfg.Restaurar = &cp
+	arrancar(t, time.Now().Add(-cp.Tiempo/timeScale), logCh, cfg)
+
+	entra := map[int]time.Duration{}
+	medidos := 0
+	timeout := time.After(time.Minute)
+	for len(entregados) < total {
+		select {
+		case ev := <-logCh:
+			u, ok := vivos[ev.CocheID]
+			switch {
+			case !ok || ev.Fase != u.Fase || !u.EnServicio:
+			case ev.Estado == EstadoEntra:
+				if _, visto := entra[ev.CocheID]; !visto {
+					entra[ev.CocheID] = ev.Elapsed
+				}
+			case ev.Estado == EstadoSale && entra[ev.CocheID] > 0:
+				// Solo el resto: ni menos, ni el servicio entero otra vez.
+				if d := ev.Elapsed - entra[ev.CocheID]; d < u.Coche.Resto-servicioTick || d > u.Coche.Resto+2*time.Second {
+					t.Errorf("coche %d fase %d: %v de servicio, le faltaban %v", ev.CocheID, u.Fase, d, u.Coche.Resto)
+				}
+				u.EnServicio = false
+				vivos[ev.CocheID] = u
+				medidos++
+			}
+			if ev.Fase == FaseEntrega && ev.Estado == EstadoSale {
+				if entregados[ev.CocheID] {
+					t.Fatalf("coche %d entregado dos veces", ev.CocheID)
+				}
+				entregados[ev.CocheID] = true
+			}
+		case <-timeout:
+			t.Fatalf("timeout, entregados %d de %d", len(entregados), total)
+		}
+	}
+	if medidos == 0 {
+		t.Error("ningún coche en servicio en la foto")
+	}
+}
+
+// Con traza de llegadas, la simulación restaurada sigue con las que aún no
+// habían entrado cuando se hizo la foto, cada una a su hora: se entregan
+// todas las de la traza, y una sola vez.
+func TestCheckpoint_RestaurarConTraza(t *testing.T) {
+	acelerar(t, func() TallerState { return TallerState{Activo: true} })
+
+	cfg := DefaultConfig()
+	cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+	for i := 0; i < 12; i++ {
+		cfg.Llegadas = append(cfg.Llegadas, Llegada{En: time.Duration(i) * 2 * time.Second, Categoria: []string{CatA, CatB, CatC}[i%3]})
+	}
+
+	primeraLogs := make(chan LogEvent, 8192)
+	primera := arrancar(t, time.Now(), primeraLogs, cfg)
+	<-scaledSleep(9 * time.Second)
+	cp := primera.Checkpoint()
+	primera.Stop()
+	if cp.Llegadas == 0 || cp.Llegadas == len(cfg.Llegadas) || cp.SiguienteID != cp.Llegadas+1 {
+		t.Fatalf("foto con %d llegadas y siguiente ID %d, quería a mitad de la traza", cp.Llegadas, cp.SiguienteID)
+	}
+
+	entregados := map[int]bool{}
+	for len(primeraLogs) > 0 {
+		if ev := <-primeraLogs; ev.Fase == FaseEntrega && ev.Estado == EstadoSale {
+			entregados[ev.CocheID] = true
+		}
+	}
+
+	logCh := make(chan LogEvent, 8192)
+	cfg.Restaurar = &cp
+	arrancar(t, time.Now().Add(-cp.Tiempo/timeScale), logCh, cfg)
+
+	timeout := time.After(time.Minute)
+	for len(entregados) < len(cfg.Llegadas) {
+		select {
+		case ev := <-logCh:
+			if ev.CocheID > len(cfg.Llegadas) {
+				t.Fatalf("coche %d: la traza solo tiene %d", ev.CocheID, len(cfg.Llegadas))
+			}
+			if ev.Fase == FaseEsperaPlaza && ev.Estado == EstadoEntra && ev.CocheID >= cp.SiguienteID {
+				if en := cfg.Llegadas[ev.CocheID-1].En; ev.Elapsed < en-servicioTick {
+					t.Errorf("coche %d llega a %v, en la traza a %v", ev.CocheID, ev.Elapsed, en)
+				}
+			}
+			if ev.Fase == FaseEntrega && ev.Estado == EstadoSale {
+				if entregados[ev.CocheID] {
+					t.Fatalf("coche %d entregado dos veces", ev.CocheID)
+				}
+				entregados[ev.CocheID] = true
+			}
+		case <-timeout:
+			t.Fatalf("timeout, entregados %d de %d", len(entregados), len(cfg.Llegadas))
+		}
+	}
+}
+
+// Mismo escenario con plazos ajustados para carrocería, con colas por
+// categoría (A->B->C) y con colas EDF. Se comparan los % de entregas a tiempo.
+func TestSLA_CategoriaVsEDF(t *testing.T) {
+	aTiempo := map[bool]int{}
+	for _, edf := range []bool{false, true} {
+		name := "CATEGORIA"
+		if edf {
+			name = "EDF"
+		}
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.NumA, cfg.NumB, cfg.NumC = 4, 4, 8
+			cfg.CapQ1, cfg.CapQ2, cfg.CapQ3 = 1000, 1000, 1000
+			cfg.Plazos = map[string]time.Duration{CatA: 90 * time.Second, CatB: 60 * time.Second, CatC: 20 * time.Second}
+			cfg.ColaEDF = edf
+
+			_, _, inf := runScenarioInforme(t, cfg)
+
+			total := 0
+			for _, inc := range incidencias() {
+				total += inf.ConPlazo[inc]
+				aTiempo[edf] += inf.ATiempo[inc]
+			}
+			if total != cfg.NumA+cfg.NumB+cfg.NumC {
+				t.Fatalf("entregas con plazo: %d, esperaba %d", total, cfg.NumA+cfg.NumB+cfg.NumC)
+			}
+			if len(inf.Tarde) != total-aTiempo[edf] {
+				t.Errorf("%d coches tarde en la lista, pero %d de %d a tiempo", len(inf.Tarde), aTiempo[edf], total)
+			}
+			t.Log(inf)
+		})
+	}
+
+	// Por categoría, las carrocerías (plazo más corto) esperan a A y B y
+	// llegan tarde; EDF las adelanta.
+	if aTiempo[true] <= aTiempo[false] {
+		t.Errorf("a tiempo: EDF %d, por categoría %d; EDF debería cumplir más plazos", aTiempo[true], aTiempo[false])
+	}
+}
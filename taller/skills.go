@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Perfil de un worker: categorías que sabe atender y multiplicador del tiempo
+// de servicio para cada una (1 = tiempo normal, 0.5 = el doble de rápido).
+// Un perfil nil es un worker generalista: todo a tiempo normal.
+type Perfil map[string]float64
+
+// Puede indica si el worker sabe atender el coche.
+func (p Perfil) Puede(c Coche) bool {
+	if p == nil {
+		return true
+	}
+	_, ok := p[c.Categoria]
+	return ok
+}
+
+// Duracion aplica el multiplicador de la categoría al tiempo de servicio.
+func (p Perfil) Duracion(cat string, d time.Duration) time.Duration {
+	m, ok := p[cat]
+	if !ok || m <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * m)
+}
+
+// perfilWorker devuelve el perfil del worker n (1, 2, ...) de un recurso.
+// Los workers sin perfil configurado son generalistas.
+func perfilWorker(perfiles map[string][]Perfil, recurso string, n int) Perfil {
+	ps := perfiles[recurso]
+	if n < 1 || n > len(ps) {
+		return nil
+	}
+	return ps[n-1]
+}
+
+// comprobarPerfiles rechaza los perfiles de un recurso desconocido y los
+// que dejan una categoría sin nadie que la atienda entre los workers con
+// que arranca cada recurso: sus coches se quedarían en la cola para siempre.
+func comprobarPerfiles(perfiles map[string][]Perfil, workers map[string]int) error {
+	for recurso, ps := range perfiles {
+		n, ok := workers[recurso]
+		if !ok {
+			return fmt.Errorf("perfiles: recurso desconocido %q (mecanicos, limpieza o entrega)", recurso)
+		}
+		if n == 0 || n > len(ps) {
+			continue // sin workers no hay a quién pedírselo; con más, hay generalistas
+		}
+		for _, cat := range []string{CatA, CatB, CatC} {
+			puede := false
+			for _, p := range ps[:n] {
+				puede = puede || p.Puede(Coche{Categoria: cat})
+			}
+			if !puede {
+				return fmt.Errorf("perfiles: ninguno de los %d workers de %s sabe atender la categoría %s", n, recurso, cat)
+			}
+		}
+	}
+	return nil
+}
+
+// hueco es la posición de un worker en Perfiles y Turnos de su recurso.
+type hueco struct {
+	recurso string
+	n       int // 1, 2, ...
+}
+
+// huecos lleva, por recurso, los huecos que tienen un worker vivo: desde que
+// arranca hasta que acaba, aunque ya lo hayan retirado y esté terminando su
+// último coche. Es del loop de la simulación.
+type huecos map[string]map[int]bool
+
+// coger da el primer hueco libre del recurso. Si los configurados están
+// todos cogidos, uno posterior: un worker sin perfil ni turno.
+func (hs huecos) coger(recurso string) hueco {
+	if hs[recurso] == nil {
+		hs[recurso] = map[int]bool{}
+	}
+	n := 1
+	for hs[recurso][n] {
+		n++
+	}
+	hs[recurso][n] = true
+	return hueco{recurso, n}
+}
+
+// soltar deja libre el hueco de un worker que ha acabado.
+func (hs huecos) soltar(h hueco) {
+	delete(hs[h.recurso], h.n)
+}
@@ -2,16 +2,32 @@ package main
 
 // Estado del taller controlado por la mutua (códigos 0..9 del enunciado).
 type TallerState struct {
-	Activo  bool // false si 0 (inactivo) o 9 (cerrado)
-	Cerrado bool
+	Activo  bool `json:"activo"` // false si 0 (inactivo) o 9 (cerrado)
+	Cerrado bool `json:"cerrado"`
 
 	// Si SoloCategoria != "" solo se permite entrar a esa categoría ("A","B","C").
-	SoloCategoria string
+	SoloCategoria string `json:"soloCategoria,omitempty"`
 
 	// Si PrioridadCategoria != "" esa categoría tiene prioridad preferente.
-	PrioridadCategoria string
+	PrioridadCategoria string `json:"prioridadCategoria,omitempty"`
+
+	// Mecánicos que se han ido a casa (códigos 10/11). Se restan de la
+	// plantilla configurada mientras dure la ausencia.
+	MecanicosFuera int `json:"mecanicosFuera,omitempty"`
+
+	// Fuente (mutua o api) cuyo estado manda según la política de -fusion.
+	Fuente string `json:"fuente,omitempty"`
 }
 
+// Códigos ampliados (fuera del 0..9 del enunciado, la mutua aleatoria nunca
+// los envía): permiten modelar que un mecánico se va a mitad de turno.
+const (
+	CodigoMecanicoSeVa   = 10
+	CodigoMecanicoVuelve = 11
+
+	maxCodigo = CodigoMecanicoVuelve
+)
+
 // Estado inicial por defecto: activo, sin restricciones, sin prioridad especial.
 func defaultState() TallerState {
 	return TallerState{
@@ -70,6 +86,14 @@ func (s *TallerState) applyCode(code int) {
 		s.Activo = false
 		s.Cerrado = true
 
+	case CodigoMecanicoSeVa:
+		s.MecanicosFuera++
+
+	case CodigoMecanicoVuelve:
+		if s.MecanicosFuera > 0 {
+			s.MecanicosFuera--
+		}
+
 	default:
 		// Fuera de rango: ignorar.
 		return
@@ -11,6 +11,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -25,12 +26,29 @@ var (
 )
 
 func main() {
+	flag.Parse()
+	if *replay != "" {
+		runReplay()
+		return
+	}
+	if *offline {
+		runOffline()
+		return
+	}
+
 	conn, err := net.Dial("tcp", "localhost:8000")
 	if err != nil {
 		logger.Fatal(err)
 	}
 	defer conn.Close()
 
+	// La simulación y la API HTTP arrancan ya, sin esperar al primer
+	// mensaje del servidor.
+	startOnce.Do(initRuntime)
+
+	// Los Ack de los códigos que llegan en sobres vuelven por la misma conexión.
+	go enviarAcks(conn)
+
 	buf := make([]byte, 512)
 	for {
 		n, err := conn.Read(buf)
@@ -58,7 +76,10 @@ func main() {
 			dispatch(msg)
 
 			// Debug temporal: lo que llega crudo desde el servidor.
-			fmt.Println("len: " + strconv.Itoa(n) + " msg: " + msg)
+			debugln("len: " + strconv.Itoa(n) + " msg: " + msg)
 		}
 	}
+
+	// El servidor ha cerrado la conexión: informe final de la ejecución.
+	printInforme()
 }
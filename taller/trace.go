@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Llegada es una entrada de la traza de llegadas: en qué instante (tiempo de
+// simulación desde el arranque) llega un coche, de qué categoría y con qué
+// plazo de entrega desde su llegada (0 = el de su categoría en Config.Plazos).
+type Llegada struct {
+	En        time.Duration
+	Categoria string
+	Plazo     time.Duration
+}
+
+// LoadLlegadas lee una traza de llegadas. Una línea por coche:
+//
+//	segundos_llegada,categoria[,segundos_plazo]
+//
+// Las líneas vacías y las que empiezan por '#' se ignoran.
+func LoadLlegadas(path string) ([]Llegada, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []Llegada
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		campos := strings.Split(line, ",")
+		if len(campos) < 2 || len(campos) > 3 {
+			return nil, fmt.Errorf("%s:%d: esperaba llegada,categoria[,plazo]", path, n)
+		}
+
+		en, err := parseSegundos(campos[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: llegada: %v", path, n, err)
+		}
+		cat := strings.ToUpper(strings.TrimSpace(campos[1]))
+		if cat != CatA && cat != CatB && cat != CatC {
+			return nil, fmt.Errorf("%s:%d: categoría desconocida %q", path, n, cat)
+		}
+		l := Llegada{En: en, Categoria: cat}
+		if len(campos) == 3 {
+			if l.Plazo, err = parseSegundos(campos[2]); err != nil {
+				return nil, fmt.Errorf("%s:%d: plazo: %v", path, n, err)
+			}
+		}
+		out = append(out, l)
+	}
+	return out, sc.Err()
+}
+
+func parseSegundos(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 {
+		return 0, fmt.Errorf("valor negativo: %v", f)
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+// inyectarLlegadas mete en fase 0 cada coche de la traza en su instante de
+// llegada, salvo las ya primeras por orden de llegada (las que entraron
+// antes del checkpoint del que se retoma).
+func (s *Simulation) inyectarLlegadas(llegadas []Llegada, ya int) {
+	ls := append([]Llegada{}, llegadas...)
+	sort.SliceStable(ls, func(i, j int) bool { return ls[i].En < ls[j].En })
+
+	for _, l := range ls[min(ya, len(ls)):] {
+		if d := l.En - simSince(s.start); d > 0 {
+			s.vida.dormir(d)
+		}
+		s.alta(newCarReq{categoria: l.Categoria, plazo: l.Plazo, traza: true})
+	}
+}
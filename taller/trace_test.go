@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// La traza admite comentarios, líneas vacías, decimales y plazo opcional;
+// los errores dicen en qué línea están.
+func TestLoadLlegadas(t *testing.T) {
+	dir := t.TempDir()
+	escribir := func(nombre, contenido string) string {
+		path := filepath.Join(dir, nombre)
+		if err := os.WriteFile(path, []byte(contenido), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	path := escribir("ok.csv", "# segundos,categoria[,plazo]\n0,A\n\n 2.5 , b ,30\n1,C,0.5\n")
+	got, err := LoadLlegadas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Llegada{
+		{En: 0, Categoria: CatA},
+		{En: 2500 * time.Millisecond, Categoria: CatB, Plazo: 30 * time.Second},
+		{En: time.Second, Categoria: CatC, Plazo: 500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%+v\nquería %+v", got, want)
+	}
+
+	for contenido, linea := range map[string]string{
+		"0,A\n1,D\n":       ":2:",
+		"0,A\n# x\n-1,B\n": ":3:",
+		"0\n":              ":1:",
+		"0,A,1,2\n":        ":1:",
+		"0,A,x\n":          ":1:",
+	} {
+		_, err := LoadLlegadas(escribir("mal.csv", contenido))
+		if err == nil || !strings.Contains(err.Error(), linea) {
+			t.Errorf("%q: %v, quería un error en la línea %s", contenido, err, linea)
+		}
+	}
+
+	if _, err := LoadLlegadas(filepath.Join(dir, "no-existe.csv")); err == nil {
+		t.Error("fichero inexistente sin error")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// vida son las goroutines de una simulación, para poder pararla entera
+// (los tests, que luego cambian stateProvider/sleepFn/simSince).
+//
+// parar cierra fin y espera a que acaben todas: los actores (recursos,
+// colas, inventario, registro, loop) salen de su bucle, y quien esté
+// esperando algo de ellos o durmiendo termina con runtime.Goexit, que
+// ejecuta sus defer igual que un return. Una vida nil no se para nunca
+// (recursos y colas sueltos, como en los tests de pool y colas).
+type vida struct {
+	fin   chan struct{}
+	una   sync.Once
+	vivas sync.WaitGroup
+}
+
+func nuevaVida() *vida {
+	return &vida{fin: make(chan struct{})}
+}
+
+// lanzar ejecuta f en una goroutine nueva de la vida.
+func (v *vida) lanzar(f func()) {
+	if v == nil {
+		go f()
+		return
+	}
+	v.vivas.Add(1)
+	go func() {
+		defer v.vivas.Done()
+		f()
+	}()
+}
+
+// parada se cierra al parar (nil, que nunca está listo, si la vida es nil).
+func (v *vida) parada() <-chan struct{} {
+	if v == nil {
+		return nil
+	}
+	return v.fin
+}
+
+// parar termina todas las goroutines de la vida y espera a que acaben.
+// No hay que llamarlo desde una de ellas.
+func (v *vida) parar() {
+	v.una.Do(func() { close(v.fin) })
+	v.vivas.Wait()
+}
+
+// dormir espera d (con sleepFn, tiempo de simulación); si se para antes,
+// termina la goroutine.
+func (v *vida) dormir(d time.Duration) {
+	select {
+	case <-sleepFn(d):
+	case <-v.parada():
+		runtime.Goexit()
+	}
+}
+
+// enviar manda x por c; si se para antes, termina la goroutine.
+func enviar[T any](v *vida, c chan<- T, x T) {
+	select {
+	case c <- x:
+	case <-v.parada():
+		runtime.Goexit()
+	}
+}
+
+// recibir espera un valor de c; si se para antes, termina la goroutine.
+func recibir[T any](v *vida, c <-chan T) T {
+	select {
+	case x := <-c:
+		return x
+	case <-v.parada():
+	}
+	runtime.Goexit()
+	panic("inalcanzable")
+}
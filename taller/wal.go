@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tipos de evento del WAL.
+const (
+	WALAlta      = "alta"      // se crea un coche (espera plaza)
+	WALCodigo    = "codigo"    // el controlador aplica un código de estado
+	WALEncola    = "encola"    // un coche entra en la cola de una fase
+	WALDesencola = "desencola" // un worker saca un coche de la cola
+	WALEntra     = "entra"     // un coche entra en servicio en una fase
+	WALSale      = "sale"      // un coche termina una fase
+)
+
+// EventoWAL es un registro del WAL. En disco, una línea por evento:
+// "crc32 json", con el CRC (IEEE, en hex) calculado sobre el JSON.
+type EventoWAL struct {
+	Seq    uint64        `json:"seq"`
+	Tiempo time.Duration `json:"t"` // reloj de simulación
+	Tipo   string        `json:"tipo"`
+	Fase   int           `json:"fase"`
+	Coche  *Coche        `json:"coche,omitempty"`
+	Frente bool          `json:"frente,omitempty"` // encolado al principio de su categoría
+	Codigo *int          `json:"codigo,omitempty"`
+	Estado *TallerState  `json:"estado,omitempty"` // estado tras aplicar el código
+}
+
+// walLog es el WAL de la ejecución (nil = desactivado). Se fija al arrancar,
+// antes de lanzar ninguna goroutine, y solo se lee después.
+var walLog *WAL
+
+// WAL es un log de eventos en disco, solo de añadir. Como el logger, lo
+// escribe una única goroutine; el resto le manda los eventos por un canal.
+// Tras cada tanda de eventos hace fsync, así lo anotado sobrevive a una caída.
+// Si falla una escritura o un fsync, el WAL queda roto: lo dice en el log,
+// no anota nada más (un hueco en medio no serviría para el replay) y Cerrar
+// devuelve el error.
+type WAL struct {
+	start time.Time
+	ch    chan EventoWAL
+	done  chan struct{}
+	err   error // lo que rompió el WAL; solo lo toca loop, se lee tras done
+}
+
+// AbrirWAL abre (o crea) el WAL en path. Con seguir, añade detrás de lo que
+// ya hubiera y sigue la numeración (descartando una última línea a medias por
+// una caída); si no, empieza un WAL nuevo y, si ya había uno, lo aparta
+// renombrándolo a path.<fecha> en vez de machacarlo.
+func AbrirWAL(path string, start time.Time, seguir bool) (*WAL, error) {
+	var evs []EventoWAL
+	var valido int64
+	flags := os.O_CREATE | os.O_WRONLY
+	if seguir {
+		var err error
+		evs, valido, err = leerWAL(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else {
+		if err := apartarWAL(path); err != nil {
+			return nil, err
+		}
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(valido); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valido, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	var seq uint64
+	if len(evs) > 0 {
+		seq = evs[len(evs)-1].Seq
+	}
+	w := &WAL{start: start, ch: make(chan EventoWAL, 1024), done: make(chan struct{})}
+	go w.loop(f, seq)
+	return w, nil
+}
+
+// apartarWAL renombra el WAL de una ejecución anterior, si lo hay.
+func apartarWAL(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	viejo := path + "." + time.Now().Format("20060102-150405.000")
+	if _, err := os.Stat(viejo); err == nil {
+		return fmt.Errorf("wal: no se puede apartar %s, ya existe %s", path, viejo)
+	}
+	if err := os.Rename(path, viejo); err != nil {
+		return err
+	}
+	log.Printf("wal: %s ya existía, se guarda como %s", path, viejo)
+	return nil
+}
+
+// Anotar añade un evento al WAL (no hace nada si el WAL está desactivado).
+func (w *WAL) Anotar(ev EventoWAL) {
+	if w == nil {
+		return
+	}
+	ev.Tiempo = simSince(w.start)
+	w.ch <- ev
+}
+
+// anotarCoche es Anotar para los eventos de un coche en una fase.
+func (w *WAL) anotarCoche(tipo string, fase int, c Coche) {
+	w.Anotar(EventoWAL{Tipo: tipo, Fase: fase, Coche: &c})
+}
+
+// Cerrar escribe lo pendiente y cierra el fichero. No se puede anotar después.
+// Devuelve el error que rompió el WAL, si lo hubo.
+func (w *WAL) Cerrar() error {
+	if w == nil {
+		return nil
+	}
+	close(w.ch)
+	<-w.done
+	return w.err
+}
+
+func (w *WAL) loop(f *os.File, seq uint64) {
+	defer close(w.done)
+	defer f.Close()
+	out := bufio.NewWriter(f)
+	escribir := func(ev EventoWAL) {
+		seq++
+		ev.Seq = seq
+		data, _ := json.Marshal(ev)
+		fmt.Fprintf(out, "%08x %s\n", crc32.ChecksumIEEE(data), data)
+	}
+
+	for ev := range w.ch {
+		if w.err != nil {
+			continue // roto: se descarta, para no bloquear a quien anota
+		}
+		escribir(ev)
+		// Agrupa lo que ya esté esperando en una sola escritura + fsync.
+		for pendientes := len(w.ch); pendientes > 0; pendientes-- {
+			escribir(<-w.ch)
+		}
+		err := out.Flush()
+		if err == nil {
+			err = f.Sync()
+		}
+		if err != nil {
+			w.err = fmt.Errorf("wal: %v", err)
+			log.Printf("%v; no se anota nada más", w.err)
+		}
+	}
+}
+
+// leerWAL lee y verifica el WAL. Devuelve los eventos válidos y hasta qué
+// byte llegan. Una última línea sin terminar (caída a mitad de escritura) no
+// es error; un CRC incorrecto o un salto en la numeración, sí.
+func leerWAL(path string) ([]EventoWAL, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var evs []EventoWAL
+	var valido int64
+	for n := 1; len(data) > 0; n++ {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break // línea a medias
+		}
+		linea := data[:i]
+		data = data[i+1:]
+
+		crc, js, ok := bytes.Cut(linea, []byte(" "))
+		var want uint32
+		if _, err := fmt.Sscanf(string(crc), "%08x", &want); !ok || err != nil || crc32.ChecksumIEEE(js) != want {
+			return evs, valido, fmt.Errorf("wal %s: línea %d: checksum incorrecto", path, n)
+		}
+		var ev EventoWAL
+		if err := json.Unmarshal(js, &ev); err != nil {
+			return evs, valido, fmt.Errorf("wal %s: línea %d: %v", path, n, err)
+		}
+		if len(evs) > 0 && ev.Seq != evs[len(evs)-1].Seq+1 {
+			return evs, valido, fmt.Errorf("wal %s: línea %d: se esperaba seq %d y hay %d", path, n, evs[len(evs)-1].Seq+1, ev.Seq)
+		}
+		evs = append(evs, ev)
+		valido += int64(i + 1)
+	}
+	return evs, valido, nil
+}
+
+// Dónde está un coche según el WAL.
+const (
+	SituacionPlaza     = "esperando plaza"
+	SituacionCola      = "en cola"
+	SituacionWorker    = "con worker" // desencolado, aún sin empezar
+	SituacionServicio  = "en servicio"
+	SituacionTerminado = "entre fases" // ha salido de la fase y aún no está en la cola siguiente
+	SituacionEntregado = "entregado"
+)
+
+// CocheWAL es el último estado conocido de un coche.
+type CocheWAL struct {
+	Coche     Coche
+	Fase      int
+	Situacion string
+}
+
+// Reconstruccion es el estado del taller rehecho a partir del WAL.
+type Reconstruccion struct {
+	Eventos int
+	Tiempo  time.Duration
+	Estado  TallerState
+	Colas   map[int]map[string][]Coche // fase -> categoría -> coches en orden de cola
+	Coches  map[int]*CocheWAL
+}
+
+// Reconstruir aplica los eventos en orden y devuelve el estado resultante.
+func Reconstruir(evs []EventoWAL) *Reconstruccion {
+	r := &Reconstruccion{
+		Estado: defaultState(),
+		Colas:  map[int]map[string][]Coche{},
+		Coches: map[int]*CocheWAL{},
+	}
+	for _, ev := range evs {
+		r.Eventos++
+		r.Tiempo = ev.Tiempo
+		if ev.Tipo == WALCodigo {
+			if ev.Estado != nil {
+				r.Estado = *ev.Estado
+			}
+			continue
+		}
+		if ev.Coche == nil {
+			continue
+		}
+
+		c := *ev.Coche
+		situacion := map[string]string{
+			WALAlta:      SituacionPlaza,
+			WALEncola:    SituacionCola,
+			WALDesencola: SituacionWorker,
+			WALEntra:     SituacionServicio,
+			WALSale:      SituacionTerminado,
+		}[ev.Tipo]
+		if ev.Tipo == WALSale && ev.Fase == FaseEntrega {
+			situacion = SituacionEntregado
+		}
+
+		// Un coche está como mucho en una cola: cualquier evento suyo lo saca
+		// de donde estuviera (tras un -restore se vuelve a encolar sin desencolar).
+		if antes, ok := r.Coches[c.ID]; ok && antes.Situacion == SituacionCola {
+			r.quitar(antes.Fase, antes.Coche)
+		}
+		r.Coches[c.ID] = &CocheWAL{Coche: c, Fase: ev.Fase, Situacion: situacion}
+
+		if ev.Tipo == WALEncola {
+			if r.Colas[ev.Fase] == nil {
+				r.Colas[ev.Fase] = map[string][]Coche{}
+			}
+			cola := r.Colas[ev.Fase]
+			if ev.Frente {
+				cola[c.Categoria] = append([]Coche{c}, cola[c.Categoria]...)
+			} else {
+				cola[c.Categoria] = append(cola[c.Categoria], c)
+			}
+		}
+	}
+	return r
+}
+
+func (r *Reconstruccion) quitar(fase int, c Coche) {
+	l := r.Colas[fase][c.Categoria]
+	for i := range l {
+		if l[i].ID == c.ID {
+			r.Colas[fase][c.Categoria] = append(l[:i:i], l[i+1:]...)
+			return
+		}
+	}
+}
+
+// String da la reconstrucción en texto: estado, colas y coches vivos.
+func (r *Reconstruccion) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "WAL: %d eventos, tiempo %v, estado %s\n", r.Eventos, r.Tiempo, stateSummary(r.Estado))
+
+	for fase := FaseMecanico; fase <= FaseEntrega; fase++ {
+		fmt.Fprintf(&b, "Cola fase %d:", fase)
+		for _, cat := range []string{CatA, CatB, CatC} {
+			fmt.Fprintf(&b, " %s[", cat)
+			for i, c := range r.Colas[fase][cat] {
+				if i > 0 {
+					b.WriteString(" ")
+				}
+				fmt.Fprintf(&b, "%d", c.ID)
+			}
+			b.WriteString("]")
+		}
+		b.WriteString("\n")
+	}
+
+	ids := make([]int, 0, len(r.Coches))
+	entregados := 0
+	for id, c := range r.Coches {
+		if c.Situacion == SituacionEntregado {
+			entregados++
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fmt.Fprintf(&b, "Coches: %d vivos, %d entregados\n", len(ids), entregados)
+	for _, id := range ids {
+		c := r.Coches[id]
+		fmt.Fprintf(&b, "  %d %s fase %d %s\n", id, c.Coche.Categoria, c.Fase, c.Situacion)
+	}
+	return b.String()
+}
+
+// ReplayWAL lee el WAL de path, lo verifica y devuelve el estado que describe.
+func ReplayWAL(path string) (*Reconstruccion, error) {
+	evs, _, err := leerWAL(path)
+	if err != nil {
+		return nil, err
+	}
+	return Reconstruir(evs), nil
+}
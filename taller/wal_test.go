@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// El WAL rehace colas, coches y estado; al seguirlo tras una caída descarta
+// la línea a medias, y un byte cambiado se detecta por el checksum.
+func TestWAL_ReplayYCorrupcion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taller.wal")
+
+	a1, a2, b3 := Coche{ID: 1, Categoria: CatA}, Coche{ID: 2, Categoria: CatA}, Coche{ID: 3, Categoria: CatB}
+	w, err := AbrirWAL(path, time.Now(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Coche{a1, a2, b3} {
+		w.anotarCoche(WALEncola, FaseMecanico, c)
+	}
+	w.anotarCoche(WALDesencola, FaseMecanico, a1)
+	w.anotarCoche(WALEntra, FaseMecanico, a1)
+	w.Cerrar()
+
+	// Caída a mitad de línea: se descarta y se sigue numerando.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`0badc0de {"seq":6,"tipo":"sa`)
+	f.Close()
+
+	w, err = AbrirWAL(path, time.Now(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, estado := 9, TallerState{Cerrado: true}
+	w.Anotar(EventoWAL{Tipo: WALCodigo, Codigo: &code, Estado: &estado})
+	w.Anotar(EventoWAL{Tipo: WALEncola, Fase: FaseMecanico, Coche: &a1, Frente: true}) // expropiado
+	w.Cerrar()
+
+	r, err := ReplayWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Eventos != 7 || !r.Estado.Cerrado {
+		t.Fatalf("eventos=%d estado=%+v, quería 7 y CERRADO", r.Eventos, r.Estado)
+	}
+	ids := func(cs []Coche) []int {
+		out := []int{}
+		for _, c := range cs {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+	if got := ids(r.Colas[FaseMecanico][CatA]); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("cola A = %v, quería [1 2]", got)
+	}
+	if got := ids(r.Colas[FaseMecanico][CatB]); !reflect.DeepEqual(got, []int{3}) {
+		t.Fatalf("cola B = %v, quería [3]", got)
+	}
+
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), `"id":3`, `"id":4`, 1)), 0o644)
+	if _, err := ReplayWAL(path); err == nil || !strings.Contains(err.Error(), "línea 3") {
+		t.Fatalf("err = %v, quería checksum incorrecto en la línea 3", err)
+	}
+}
+
+// Sin seguir, un WAL que ya existe se aparta con otro nombre en vez de
+// empezar encima.
+func TestWAL_ApartaElAnterior(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taller.wal")
+	for _, id := range []int{1, 2} {
+		w, err := AbrirWAL(path, time.Now(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.anotarCoche(WALAlta, FaseEsperaPlaza, Coche{ID: id, Categoria: CatA})
+		if err := w.Cerrar(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	viejos, _ := filepath.Glob(path + ".*")
+	if len(viejos) != 1 {
+		t.Fatalf("apartados: %v, quería uno", viejos)
+	}
+	for p, id := range map[string]int{viejos[0]: 1, path: 2} {
+		r, err := ReplayWAL(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Eventos != 1 || r.Coches[id] == nil {
+			t.Fatalf("%s: %d eventos, coches %v; quería solo el %d", p, r.Eventos, r.Coches, id)
+		}
+	}
+}
+
+// Si no se puede escribir, el WAL se rompe: no bloquea a quien anota y
+// Cerrar devuelve el error.
+func TestWAL_Roto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taller.wal")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path) // solo lectura: falla al escribir
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &WAL{start: time.Now(), ch: make(chan EventoWAL, 1024), done: make(chan struct{})}
+	go w.loop(f, 0)
+
+	for id := 1; id <= 5000; id++ {
+		w.anotarCoche(WALAlta, FaseEsperaPlaza, Coche{ID: id, Categoria: CatA})
+	}
+	if err := w.Cerrar(); err == nil {
+		t.Fatal("Cerrar sin error con el WAL roto")
+	}
+}